@@ -0,0 +1,123 @@
+// Package authtoken signs and verifies the JWT access tokens the API issues.
+//
+// Tokens are signed with EdDSA or RS256 keys loaded from PEM files, each named by
+// a kid header so several keys can verify at once while keys are rotated. Without
+// any keys it falls back to HS256 with the shared JWT secret, in which case no
+// public keys are published.
+package authtoken
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims are the contents of an access token
+type Claims struct {
+	UserID    int    `json:"user_id"`
+	Email     string `json:"email"`
+	SessionID int    `json:"sid"` // the session logging out revokes
+	// Type is empty for access tokens; other tokens signed with the same secret set it
+	Type string `json:"typ,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// ErrInvalid is returned for tokens that are malformed, expired, forged or of the wrong kind
+var ErrInvalid = errors.New("authtoken: invalid token")
+
+// key is one signing key pair; private is nil for keys that only verify
+type key struct {
+	id      string
+	method  jwt.SigningMethod
+	private any
+	public  any
+}
+
+// Keys signs with one active key and verifies with all keys it knows
+type Keys struct {
+	active *key
+	all    []*key // in the order they were loaded
+	byID   map[string]*key
+	now    func() time.Time // nil means the wall clock
+}
+
+// NewHMAC returns keys that sign and verify with a shared secret
+func NewHMAC(secret []byte) *Keys {
+	k := &key{method: jwt.SigningMethodHS256, private: secret, public: secret}
+	return &Keys{active: k, all: []*key{k}, byID: map[string]*key{"": k}}
+}
+
+// SetClock replaces the clock expiry is checked against, so tests can pin "now"
+func (ks *Keys) SetClock(now func() time.Time) {
+	ks.now = now
+}
+
+// Algorithm returns the signing algorithm of the active key, such as "EdDSA"
+func (ks *Keys) Algorithm() string {
+	return ks.active.method.Alg()
+}
+
+// KeyID returns the kid of the active key, empty for a shared secret
+func (ks *Keys) KeyID() string {
+	return ks.active.id
+}
+
+// InternalSecret returns the HMAC secret of tokens only this backend reads. With a
+// shared secret it is that secret; with keys it is derived from the active private
+// key, so replicas loading the same keys agree on it. Rotating the signing key
+// invalidates those tokens, which are short-lived.
+func (ks *Keys) InternalSecret() ([]byte, error) {
+	if secret, ok := ks.active.private.([]byte); ok {
+		return secret, nil
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(ks.active.private)
+	if err != nil {
+		return nil, fmt.Errorf("authtoken: %w", err)
+	}
+	return hkdf.Key(sha256.New, der, nil, "habit-tracker internal tokens", 32)
+}
+
+// Sign returns claims as a signed token
+func (ks *Keys) Sign(claims *Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.method, claims)
+	if ks.active.id != "" {
+		token.Header["kid"] = ks.active.id
+	}
+	return token.SignedString(ks.active.private)
+}
+
+// Verify checks a token's signature and expiry and returns its claims
+func (ks *Keys) Verify(raw string) (*Claims, error) {
+	var claims Claims
+	opts := []jwt.ParserOption{jwt.WithExpirationRequired()}
+	if ks.now != nil {
+		opts = append(opts, jwt.WithTimeFunc(ks.now))
+	}
+	_, err := jwt.ParseWithClaims(raw, &claims, ks.keyFunc, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if claims.Type != "" || claims.ID == "" {
+		return nil, fmt.Errorf("%w: not an access token", ErrInvalid)
+	}
+	return &claims, nil
+}
+
+// keyFunc picks the verification key named by the token, insisting on the
+// algorithm that key was loaded for so one kind of key cannot stand in for another
+func (ks *Keys) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	k, ok := ks.byID[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return k.public, nil
+}
@@ -0,0 +1,194 @@
+package authtoken
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var issued = time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+func accessClaims(ttl time.Duration) *Claims {
+	return &Claims{
+		UserID:    7,
+		Email:     "ann@example.com",
+		SessionID: 3,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti-1",
+			IssuedAt:  jwt.NewNumericDate(issued),
+			ExpiresAt: jwt.NewNumericDate(issued.Add(ttl)),
+		},
+	}
+}
+
+// writeKey writes a new Ed25519 private key as PKCS#8 PEM and returns its path
+func writeKey(t *testing.T, name string) string {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVerifyExpiryWithClock(t *testing.T) {
+	ks := NewHMAC([]byte("secret"))
+	raw, err := ks.Sign(accessClaims(15 * time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		ok   bool
+	}{
+		{"just issued", issued, true},
+		{"a second before expiry", issued.Add(15*time.Minute - time.Second), true},
+		{"at expiry", issued.Add(15 * time.Minute), false},
+		{"a day later", issued.Add(24 * time.Hour), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks.SetClock(func() time.Time { return tt.at })
+			claims, err := ks.Verify(raw)
+			if tt.ok != (err == nil) {
+				t.Fatalf("Verify error = %v, want ok %v", err, tt.ok)
+			}
+			if err != nil && !errors.Is(err, ErrInvalid) {
+				t.Errorf("error %v is not ErrInvalid", err)
+			}
+			if tt.ok && (claims.UserID != 7 || claims.SessionID != 3) {
+				t.Errorf("claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsOtherTokens(t *testing.T) {
+	ks := NewHMAC([]byte("secret"))
+	ks.SetClock(func() time.Time { return issued })
+
+	noExpiry := accessClaims(0)
+	noExpiry.ExpiresAt = nil
+	noID := accessClaims(time.Hour)
+	noID.ID = ""
+	internal := accessClaims(time.Hour)
+	internal.Type = "mfa_pending"
+
+	for name, claims := range map[string]*Claims{"no expiry": noExpiry, "no jti": noID, "internal token": internal} {
+		raw, err := ks.Sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ks.Verify(raw); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: Verify error = %v, want ErrInvalid", name, err)
+		}
+	}
+
+	forged, err := NewHMAC([]byte("other")).Sign(accessClaims(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Verify(forged); !errors.Is(err, ErrInvalid) {
+		t.Errorf("forged: Verify error = %v, want ErrInvalid", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey, newKey := writeKey(t, "old.pem"), writeKey(t, "new.pem")
+	now := func() time.Time { return issued }
+
+	before, err := LoadKeys([]string{oldKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.Algorithm() != "EdDSA" || before.KeyID() == "" {
+		t.Fatalf("alg %q kid %q", before.Algorithm(), before.KeyID())
+	}
+	oldToken, err := before.Sign(accessClaims(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := LoadKeys([]string{newKey, oldKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after.SetClock(now)
+	if after.KeyID() == before.KeyID() {
+		t.Fatal("the new key got the old kid")
+	}
+	if _, err := after.Verify(oldToken); err != nil {
+		t.Errorf("token of the retired key rejected: %v", err)
+	}
+	if set := after.JWKS(); len(set.Keys) != 2 || set.Keys[0].Kid != after.KeyID() {
+		t.Errorf("JWKS = %+v, want the active key first of two", set)
+	}
+
+	// Once the old key is dropped its tokens stop verifying
+	dropped, err := LoadKeys([]string{newKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropped.SetClock(now)
+	if _, err := dropped.Verify(oldToken); !errors.Is(err, ErrInvalid) {
+		t.Errorf("token of a dropped key: Verify error = %v, want ErrInvalid", err)
+	}
+
+	// A shared-secret token carrying a known kid must not be accepted
+	hmacToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims(time.Hour))
+	hmacToken.Header["kid"] = after.KeyID()
+	raw, err := hmacToken.SignedString([]byte("guess"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := after.Verify(raw); !errors.Is(err, ErrInvalid) {
+		t.Errorf("HS256 token with an EdDSA kid: Verify error = %v, want ErrInvalid", err)
+	}
+}
+
+func TestInternalSecret(t *testing.T) {
+	secret, err := NewHMAC([]byte("secret")).InternalSecret()
+	if err != nil || string(secret) != "secret" {
+		t.Errorf("InternalSecret = %q, %v; want the shared secret", secret, err)
+	}
+
+	// Every replica loading the key derives the same secret, another key another one
+	path := writeKey(t, "a.pem")
+	derive := func(paths ...string) []byte {
+		t.Helper()
+		ks, err := LoadKeys(paths)
+		if err != nil {
+			t.Fatal(err)
+		}
+		secret, err := ks.InternalSecret()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return secret
+	}
+	first, again, other := derive(path), derive(path), derive(writeKey(t, "b.pem"), path)
+	if len(first) != 32 || string(first) != string(again) {
+		t.Errorf("derived %x then %x, want the same 32 bytes", first, again)
+	}
+	if string(other) == string(first) {
+		t.Error("a different signing key derived the same secret")
+	}
+}
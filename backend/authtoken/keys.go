@@ -0,0 +1,160 @@
+package authtoken
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSABits is the smallest RSA key accepted
+const minRSABits = 2048
+
+// LoadKeys reads PEM keys from paths. The first must be a private key and signs
+// new tokens; the others, private or public, only verify tokens issued before a
+// rotation. Ed25519 keys sign with EdDSA and RSA keys with RS256.
+func LoadKeys(paths []string) (*Keys, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("authtoken: no keys given")
+	}
+	ks := &Keys{byID: make(map[string]*key)}
+	for i, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("authtoken: %w", err)
+		}
+		k, err := parseKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("authtoken: %s: %w", path, err)
+		}
+		if i == 0 {
+			if k.private == nil {
+				return nil, fmt.Errorf("authtoken: %s: the signing key must be a private key", path)
+			}
+			ks.active = k
+		}
+		if _, dup := ks.byID[k.id]; dup {
+			return nil, fmt.Errorf("authtoken: %s: key is listed twice", path)
+		}
+		ks.byID[k.id] = k
+		ks.all = append(ks.all, k)
+	}
+	return ks, nil
+}
+
+// parseKey reads the first PEM block of data, which may hold a PKCS#8 or PKCS#1
+// private key or a PKIX or PKCS#1 public key
+func parseKey(data []byte) (*key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	k := &key{}
+	switch p := parsed.(type) {
+	case ed25519.PrivateKey:
+		k.private, k.public = p, p.Public()
+	case *rsa.PrivateKey:
+		k.private, k.public = p, &p.PublicKey
+	case ed25519.PublicKey, *rsa.PublicKey:
+		k.public = p
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use Ed25519 or RSA", parsed)
+	}
+
+	switch pub := k.public.(type) {
+	case ed25519.PublicKey:
+		k.method = jwt.SigningMethodEdDSA
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key has %d bits, at least %d are required", pub.N.BitLen(), minRSABits)
+		}
+		k.method = jwt.SigningMethodRS256
+	}
+	k.id = publicJWK(k).thumbprint()
+	return k, nil
+}
+
+// JWK is a public key in JSON Web Key form (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set as served at /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public half of every verification key, active key first.
+// It is empty for a shared secret.
+func (ks *Keys) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	if ks.active.id == "" {
+		return set
+	}
+	for _, k := range ks.all {
+		set.Keys = append(set.Keys, publicJWK(k))
+	}
+	return set
+}
+
+func publicJWK(k *key) JWK {
+	jwk := JWK{Kid: k.id, Use: "sig", Alg: k.method.Alg()}
+	switch pub := k.public.(type) {
+	case ed25519.PublicKey:
+		jwk.Kty, jwk.Crv, jwk.X = "OKP", "Ed25519", base64.RawURLEncoding.EncodeToString(pub)
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	}
+	return jwk
+}
+
+// thumbprint is the RFC 7638 thumbprint of the key, used as its kid so the same
+// key file always gets the same id
+func (j JWK) thumbprint() string {
+	// The required members in lexicographic order, without whitespace
+	var canonical string
+	switch j.Kty {
+	case "OKP":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q}`, j.Crv, j.Kty, j.X)
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":%q,"kty":%q,"n":%q}`, j.E, j.Kty, j.N)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -1,8 +0,0 @@
-package main
-
-var jwtSecret = []byte("your_secret_key")
-
-// GetJWTSecret returns the JWT secret
-func GetJWTSecret() []byte {
-	return jwtSecret
-}
\ No newline at end of file
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"habit-tracker/backend/config"
+	"log"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadConfig parses args into a Config, exiting if it cannot be loaded or is invalid
+func loadConfig(fs *flag.FlagSet, args []string) *config.Config {
+	loader := config.NewLoader(fs)
+	fs.Parse(args)
+
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Fatalf("❌ Error loading configuration: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("❌ Invalid configuration: %v", err)
+	}
+	if !cfg.IsProduction() && cfg.JWTSecret == config.PlaceholderJWTSecret {
+		log.Println("⚠️  Using the placeholder JWT secret, set HABIT_JWT_SECRET before deploying")
+	}
+	return cfg
+}
+
+// runConfig implements the "config print" subcommand
+func runConfig(args []string) {
+	fs := flag.NewFlagSet("config", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: backend config [flags] print")
+		fs.PrintDefaults()
+	}
+
+	loader := config.NewLoader(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 || fs.Arg(0) != "print" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Fatalf("❌ Error loading configuration: %v", err)
+	}
+
+	out, err := yaml.Marshal(cfg.Redacted())
+	if err != nil {
+		log.Fatalf("❌ Error encoding configuration: %v", err)
+	}
+	fmt.Print(string(out))
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("❌ Invalid configuration: %v", err)
+	}
+}
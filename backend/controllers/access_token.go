@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"errors"
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/store"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessTokenPrefix starts every personal access token, telling them apart from JWTs
+const AccessTokenPrefix = "hpat_"
+
+// GET /tokens
+func (h *Handler) ListAccessTokens(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	tokens, err := h.store.ListAccessTokens(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// POST /tokens
+func (h *Handler) CreateAccessToken(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Name   string   `json:"name" binding:"required"`
+		Scopes []string `json:"scopes"`
+		// ExpiresInDays is optional, tokens without it never expire
+		ExpiresInDays int `json:"expires_in_days" binding:"min=0"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+		return
+	}
+	if err := models.ValidateScopes(input.Scopes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	raw := AccessTokenPrefix + secret
+
+	token := models.AccessToken{
+		UserID:    userID,
+		Name:      name,
+		Scopes:    input.Scopes,
+		TokenHash: store.HashToken(raw),
+		Prefix:    raw[:len(AccessTokenPrefix)+6],
+		CreatedAt: time.Now(),
+	}
+	if input.ExpiresInDays > 0 {
+		expiresAt := token.CreatedAt.AddDate(0, 0, input.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := h.store.CreateAccessToken(c.Request.Context(), &token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+
+	// The token itself is only ever shown here
+	c.JSON(http.StatusCreated, gin.H{
+		"token":        raw,
+		"access_token": token,
+	})
+}
+
+// DELETE /tokens/:id
+func (h *Handler) DeleteAccessToken(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	tokenID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	err = h.store.DeleteAccessToken(c.Request.Context(), tokenID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Token revoked"})
+}
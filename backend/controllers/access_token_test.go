@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/store"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAccessTokens(t *testing.T) {
+	e := newTestEnv(t)
+	_, ann := e.signUp("ann@example.com")
+	_, bob := e.signUp("bob@example.com")
+
+	expect(t, e.do("POST", "/tokens", ann, gin.H{"name": "cli"}), http.StatusBadRequest, nil)
+	expect(t, e.do("POST", "/tokens", ann, gin.H{"name": "cli", "scopes": []string{"admin"}}), http.StatusBadRequest, nil)
+
+	var created struct {
+		Token       string             `json:"token"`
+		AccessToken models.AccessToken `json:"access_token"`
+	}
+	w := e.do("POST", "/tokens", ann, gin.H{"name": "cli", "scopes": []string{models.ScopeRead}, "expires_in_days": 30})
+	expect(t, w, http.StatusCreated, &created)
+	if !strings.HasPrefix(created.Token, AccessTokenPrefix) || !strings.HasPrefix(created.Token, created.AccessToken.Prefix) {
+		t.Errorf("token %q with prefix %q, want both to start with %s", created.Token, created.AccessToken.Prefix, AccessTokenPrefix)
+	}
+	if created.AccessToken.ExpiresAt == nil {
+		t.Error("the token does not expire")
+	}
+
+	// The list never shows the secret or its hash
+	w = e.do("GET", "/tokens", ann, nil)
+	var tokens []map[string]json.RawMessage
+	expect(t, w, http.StatusOK, &tokens)
+	if len(tokens) != 1 || tokens[0]["token_hash"] != nil || strings.Contains(w.Body.String(), created.Token) {
+		t.Errorf("tokens = %s, want one without its secret", w.Body)
+	}
+
+	path := "/tokens/" + string(tokens[0]["id"])
+	expect(t, e.do("DELETE", path, bob, nil), http.StatusNotFound, nil)
+	expect(t, e.do("DELETE", path, ann, nil), http.StatusOK, nil)
+	expect(t, e.do("GET", "/tokens", ann, nil), http.StatusOK, &tokens)
+	if len(tokens) != 0 {
+		t.Errorf("%d tokens left, want none", len(tokens))
+	}
+}
+
+func TestChangePasswordRevokesAccessTokens(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+
+	var created struct {
+		Token string `json:"token"`
+	}
+	expect(t, e.do("POST", "/tokens", token, gin.H{"name": "cli", "scopes": []string{models.ScopeRead}}), http.StatusCreated, &created)
+
+	var changed loginResponse
+	w := e.do("POST", "/me/password", token, gin.H{"current_password": testPassword, "new_password": "a brand new password"})
+	expect(t, w, http.StatusOK, &changed)
+
+	if _, err := e.store.GetAccessTokenByHash(context.Background(), store.HashToken(created.Token)); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("looking up the token after a password change: %v, want ErrNotFound", err)
+	}
+	var tokens []models.AccessToken
+	expect(t, e.do("GET", "/tokens", changed.Token, nil), http.StatusOK, &tokens)
+	if len(tokens) != 0 {
+		t.Errorf("%d tokens left, want none", len(tokens))
+	}
+}
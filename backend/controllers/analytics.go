@@ -1,25 +1,20 @@
 package controllers
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/store"
 	"net/http"
-	"sort"
 	"strconv"
-	"time"
-	
+
 	"github.com/gin-gonic/gin"
 )
 
-type HabitAnalytics struct {
-	CurrentStreak  int
-	LongestStreak  int
-	CompletionRate string
-}
-
-func GetHabitAnalytics(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+// GET /habits/:id/analytics
+func (h *Handler) GetHabitAnalytics(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
@@ -31,183 +26,156 @@ func GetHabitAnalytics(c *gin.Context) {
 	}
 
 	// Step 1: Check if habit exists and belongs to user
-	var title string
-	err = db.QueryRow("SELECT title FROM habits WHERE id = $1 AND user_id = $2", habitID, userID).Scan(&title)
-	if err != nil {
+	habit, err := h.store.GetHabit(c.Request.Context(), habitID, userID)
+	if errors.Is(err, store.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch habit"})
+		return
+	}
 
-	// Step 2: Fetch all completion dates
-	rows, err := db.Query(`
-		SELECT date_completed
-		FROM habit_completions
-		WHERE habit_id = $1 AND user_id = $2
-		ORDER BY date_completed ASC
-	`, habitID, userID)
+	loc, err := h.userLocation(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
 
+	// Step 2: Fetch all completion dates
+	dates, err := h.completedDates(c.Request.Context(), habit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch completions"})
 		return
 	}
-	defer rows.Close()
 
-	var dates []time.Time
-	for rows.Next() {
-		var d time.Time
-		if err := rows.Scan(&d); err == nil {
-			dates = append(dates, d)
-		}
-	}
+	// Step 3: Compute Analytics
+	analytics := h.computeStreaks(habit, dates, loc)
+	response := gin.H{
+		"habit_id":        habitID,
+		"title":           habit.Title,
+		"kind":            habit.Kind,
+		"current_streak":  analytics.Current,
+		"longest_streak":  analytics.Longest,
+		"strength":        models.RoundStrength(analytics.Strength),
+		"start_date":      nil,
+		"completion_rate": fmt.Sprintf("%.2f%%", analytics.CompletionRate),
+		"segments":        analytics.Segments,
+	}
+	if !analytics.Start.IsZero() {
+		response["start_date"] = analytics.Start.Format("2006-01-02")
+	}
+	// Quit habits count slips, and every day without one is a success
+	if habit.Kind == models.HabitQuit {
+		response["total_slips"] = len(dates)
+	} else {
+		response["total_completions"] = len(dates)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
 
-	if len(dates) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"habit_id":        habitID,
-			"title":           title,
-			"current_streak":  0,
-			"longest_streak":  0,
-			"total_completions": 0,
-			"start_date":      nil,
-			"completion_rate": "0%",
-		})
+// GET /habits/summary - Get overall habit summary for dashboard
+func (h *Handler) GetHabitSummary(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
+	ctx := c.Request.Context()
 
-	// Step 3: Compute Analytics
-	analytics := ComputeHabitAnalytics(dates)
+	// Get total habits count
+	totalHabits, err := h.store.CountHabits(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total habits"})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"habit_id":          habitID,
-		"title":             title,
-		"current_streak":    analytics.CurrentStreak,
-		"longest_streak":    analytics.LongestStreak,
-		"total_completions": len(dates),
-		"start_date":        dates[0].Format("2006-01-02"),
-		"completion_rate":   analytics.CompletionRate,
-	})
-}
+	// Get total completions count of build habits
+	totalCompletions, err := h.store.CountCompletions(ctx, userID, models.HabitBuild)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total completions"})
+		return
+	}
 
-func ComputeHabitAnalytics(dates []time.Time) HabitAnalytics {
-	if len(dates) == 0 {
-		return HabitAnalytics{}
+	// Get total slips count of quit habits
+	totalSlips, err := h.store.CountCompletions(ctx, userID, models.HabitQuit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total slips"})
+		return
 	}
 
-	// Normalize dates: strip time component (in case any time is set)
-	dateMap := make(map[time.Time]bool)
-	for _, d := range dates {
-		normalized := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, d.Location())
-		dateMap[normalized] = true
+	// Get longest streak across all habits, clean-day streaks of quit habits included
+	longestStreak, err := h.store.LongestStreak(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch longest streak"})
+		return
 	}
 
-	// Convert map to sorted slice again
-	var allDates []time.Time
-	for d := range dateMap {
-		allDates = append(allDates, d)
+	// Get most consistent habit (highest strength score), empty if the user has no streaks yet
+	mostConsistent, err := h.store.MostConsistentHabit(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch most consistent habit"})
+		return
 	}
-	sort.Slice(allDates, func(i, j int) bool {
-		return allDates[i].Before(allDates[j])
-	})
 
-	// Initialize streak counters
-	currentStreak := 0
-	longestStreak := 0
-	streak := 0
-
-	// Used to calculate streaks
-	for i := 0; i < len(allDates); i++ {
-		if i == 0 || allDates[i].Sub(allDates[i-1]) == 24*time.Hour {
-			streak++
-		} else {
-			streak = 1
-		}
-		if streak > longestStreak {
-			longestStreak = streak
-		}
-	}
-
-	// Calculate current streak (check backwards from today)
-	today := time.Now()
-	for i := len(allDates) - 1; i >= 0; i-- {
-		d := allDates[i]
-		if today.Sub(d).Hours() > 24 {
-			break
-		}
-		if today.Sub(d).Hours() <= 24 && today.Sub(d).Hours() >= 0 {
-			currentStreak++
-			today = d
-		}
-	}
-
-	// Completion rate = (completions / days from start) * 100
-	startDate := allDates[0]
-	daysSinceStart := int(time.Since(startDate).Hours()/24) + 1
-	completionRate := float64(len(allDates)) / float64(daysSinceStart) * 100
-
-	return HabitAnalytics{
-		CurrentStreak:  currentStreak,
-		LongestStreak:  longestStreak,
-		CompletionRate: fmt.Sprintf("%.2f%%", completionRate),
+	summary := gin.H{
+		"total_habits":      totalHabits,
+		"total_completions": totalCompletions,
+		"total_slips":       totalSlips,
+		"longest_streak":    longestStreak,
+		"most_consistent":   mostConsistent,
 	}
+
+	c.JSON(http.StatusOK, summary)
 }
 
-// GET /habits/summary - Get overall habit summary for dashboard
-func GetHabitSummary(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+// GET /habits/:id/strength?from=&to= - the daily strength score history for charting
+func (h *Handler) GetHabitStrength(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	// Get total habits count
-	var totalHabits int
-	err := db.QueryRow(`SELECT COUNT(*) FROM habits WHERE user_id=$1`, userID).Scan(&totalHabits)
+	habitID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total habits"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid habit ID"})
 		return
 	}
 
-	// Get total completions count
-	var totalCompletions int
-	err = db.QueryRow(`SELECT COUNT(*) FROM habit_completions WHERE user_id=$1`, userID).Scan(&totalCompletions)
+	ctx := c.Request.Context()
+	habit, err := h.store.GetHabit(ctx, habitID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found"})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total completions"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch habit"})
 		return
 	}
 
-	// Get longest streak across all habits
-	var longestStreak int
-	err = db.QueryRow(`SELECT COALESCE(MAX(longest_streak), 0) FROM habit_streaks WHERE user_id=$1`, userID).Scan(&longestStreak)
+	loc, err := h.userLocation(ctx, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch longest streak"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
 		return
 	}
-
-	// Get most consistent habit (highest current streak)
-	var mostConsistentHabitTitle sql.NullString
-	err = db.QueryRow(`
-		SELECT h.title
-		FROM habit_streaks s
-		JOIN habits h ON s.habit_id = h.id
-		WHERE s.user_id=$1
-		ORDER BY s.current_streak DESC
-		LIMIT 1
-	`, userID).Scan(&mostConsistentHabitTitle)
-	
-	// Handle case where user has no habits or streaks
-	mostConsistent := ""
-	if err != nil && err != sql.ErrNoRows {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch most consistent habit"})
+	from, to, ok := h.calendarRange(c, loc)
+	if !ok {
 		return
-	} else if mostConsistentHabitTitle.Valid {
-		mostConsistent = mostConsistentHabitTitle.String
 	}
 
-	summary := gin.H{
-		"total_habits":       totalHabits,
-		"total_completions":  totalCompletions,
-		"longest_streak":     longestStreak,
-		"most_consistent":    mostConsistent,
+	history, err := h.store.StrengthHistory(ctx, habit.ID, userID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch strength history"})
+		return
 	}
 
-	c.JSON(http.StatusOK, summary)
-}
\ No newline at end of file
+	c.JSON(http.StatusOK, gin.H{
+		"habit_id": habit.ID,
+		"title":    habit.Title,
+		"from":     from.Format("2006-01-02"),
+		"to":       to.Format("2006-01-02"),
+		"days":     history,
+	})
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"habit-tracker/backend/models"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHabitAnalytics(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Read"})
+	e.complete(token, habit.ID, day(-4), day(-3), day(-1), day(0))
+
+	var got struct {
+		CurrentStreak    int    `json:"current_streak"`
+		LongestStreak    int    `json:"longest_streak"`
+		StartDate        string `json:"start_date"`
+		CompletionRate   string `json:"completion_rate"`
+		TotalCompletions int    `json:"total_completions"`
+	}
+	expect(t, e.do("GET", habitPath(habit.ID, "/analytics"), token, nil), http.StatusOK, &got)
+	// The rate counts from the first completion, not from when the habit was created
+	if got.CurrentStreak != 2 || got.LongestStreak != 2 || got.StartDate != day(-4) || got.CompletionRate != "80.00%" || got.TotalCompletions != 4 {
+		t.Errorf("analytics = %+v, want streaks 2/2 from %s at 80.00%% with 4 completions", got, day(-4))
+	}
+
+	expect(t, e.do("GET", habitPath(habit.ID+1, "/analytics"), token, nil), http.StatusNotFound, nil)
+}
+
+// brokenHabitStore fails every habit lookup as an unreachable database would
+type brokenHabitStore struct {
+	*fakeStore
+}
+
+func (brokenHabitStore) GetHabit(ctx context.Context, habitID, userID int) (*models.Habit, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestHabitAnalyticsStoreFailure(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Read"})
+
+	// A failing database is not a missing habit
+	e.h.store = brokenHabitStore{e.store}
+	expect(t, e.do("GET", habitPath(habit.ID, "/analytics"), token, nil), http.StatusInternalServerError, nil)
+}
+
+func TestHabitStrength(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Read"})
+	e.complete(token, habit.ID, day(-2), day(-1), day(0))
+
+	var strength struct {
+		Days []struct {
+			Date     string  `json:"date"`
+			Strength float64 `json:"strength"`
+		} `json:"days"`
+	}
+	expect(t, e.do("GET", habitPath(habit.ID, "/strength?from="+day(-14)+"&to="+day(-1)), token, nil), http.StatusOK, &strength)
+
+	if len(strength.Days) != 2 {
+		t.Fatalf("days = %+v, want %s and %s", strength.Days, day(-2), day(-1))
+	}
+	if first, second := strength.Days[0].Strength, strength.Days[1].Strength; first <= 0 || second <= first {
+		t.Errorf("strength went %v then %v, want it to grow", first, second)
+	}
+
+	expect(t, e.do("GET", habitPath(habit.ID, "/strength?from="+day(-1)+"&to="+day(-14)), token, nil), http.StatusBadRequest, nil)
+}
+
+func TestHabitSummary(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	read := e.createHabit(token, gin.H{"title": "Read"})
+	walk := e.createHabit(token, gin.H{"title": "Walk"})
+	smoking := e.createHabit(token, gin.H{"title": "Smoking", "kind": "quit"})
+	e.complete(token, read.ID, day(-2), day(-1), day(0))
+	e.complete(token, walk.ID, day(-1))
+	e.complete(token, smoking.ID, day(-1))
+
+	type summary struct {
+		TotalHabits      int    `json:"total_habits"`
+		TotalCompletions int    `json:"total_completions"`
+		TotalSlips       int    `json:"total_slips"`
+		LongestStreak    int    `json:"longest_streak"`
+		MostConsistent   string `json:"most_consistent"`
+	}
+	var got summary
+	expect(t, e.do("GET", "/habits/summary", token, nil), http.StatusOK, &got)
+	if want := (summary{3, 4, 1, 3, "Read"}); got != want {
+		t.Errorf("summary = %+v, want %+v", got, want)
+	}
+}
@@ -1,17 +1,70 @@
 package controllers
 
 import (
+	"errors"
 	"habit-tracker/backend/models"
+	"habit-tracker/backend/ratelimit"
+	"habit-tracker/backend/store"
+	"log"
 	"net/http"
+	"strings"
 	"time"
-	
+
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Per-account limits; the per-IP limits are applied by middleware on the routes
+var (
+	loginAccountLimit    = ratelimit.PerMinute(10)
+	registerAccountLimit = ratelimit.PerHour(3)
+	// loginBackoff locks an account for a minute after 5 wrong passwords in a row,
+	// doubling with each further one up to an hour. An hour without failures, once
+	// any lockout is over, forgets them.
+	loginBackoff = ratelimit.Backoff{Threshold: 5, Base: time.Minute, Max: time.Hour, Window: time.Hour}
+)
+
+// allow enforces a lockout and limit on key, writing a 429 response and returning false if exceeded
+func (h *Handler) allow(c *gin.Context, key string, limit ratelimit.Limit) bool {
+	ctx := c.Request.Context()
+	locked, err := h.opts.Limiter.LockedFor(ctx, key)
+	if err == nil && locked == 0 {
+		locked, err = h.opts.Limiter.Allow(ctx, key, limit)
+	}
+	if err != nil {
+		// Fail open, as the middleware does
+		log.Printf("⚠️  Rate limit check failed: %v", err)
+		return true
+	}
+	if locked > 0 {
+		ratelimit.TooManyRequests(c, locked)
+		return false
+	}
+	return true
+}
+
+// loginFailed records a failed attempt on key and responds with message, or reports
+// the lockout if it triggered one
+func (h *Handler) loginFailed(c *gin.Context, key, message string) {
+	h.attemptFailed(c, key, http.StatusUnauthorized, message)
+}
+
+// attemptFailed is loginFailed for checks that answer a wrong secret with status,
+// such as re-entering a code while already logged in
+func (h *Handler) attemptFailed(c *gin.Context, key string, status int, message string) {
+	lockout, err := h.opts.Limiter.RecordFailure(c.Request.Context(), key, loginBackoff)
+	if err != nil {
+		log.Printf("⚠️  Failed to record login failure: %v", err)
+	}
+	if lockout > 0 {
+		ratelimit.TooManyRequests(c, lockout)
+		return
+	}
+	c.JSON(status, gin.H{"error": message})
+}
+
 // POST /users
-func RegisterUser(c *gin.Context) {
+func (h *Handler) RegisterUser(c *gin.Context) {
 	var user models.User
 
 	// Parse JSON from request into user struct
@@ -20,6 +73,20 @@ func RegisterUser(c *gin.Context) {
 		return
 	}
 
+	// Days are counted in the user's time zone, UTC unless they pick one
+	if user.TimeZone == "" {
+		user.TimeZone = models.DefaultTimeZone
+	}
+	if _, err := models.ParseTimeZone(user.TimeZone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Throttled before hashing, which is deliberately slow
+	if !h.allow(c, "register:"+strings.ToLower(user.Email), registerAccountLimit) {
+		return
+	}
+
 	// Hash the password before saving
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -27,25 +94,27 @@ func RegisterUser(c *gin.Context) {
 		return
 	}
 	user.Password = string(hashedPassword)
+	user.EmailVerified = false
 	user.CreatedAt = time.Now()
 
 	// Save user to database
-	var userID int
-	query := `INSERT INTO users(username, email, password, created_at) VALUES ($1, $2, $3, $4) RETURNING id`
-	err = db.QueryRow(query, user.Username, user.Email, user.Password, user.CreatedAt).Scan(&userID)
-	if err != nil {
+	if err := h.store.CreateUser(c.Request.Context(), &user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
 
+	// A failed mail is not fatal, the user can ask for the link again
+	if err := h.sendVerificationEmail(c.Request.Context(), &user); err != nil {
+		log.Printf("❌ Failed to send verification email to user %d: %v", user.ID, err)
+	}
+
 	// Don't return hashed password in the response
-	user.ID = userID
 	user.Password = ""
 	c.JSON(http.StatusCreated, user)
 }
 
 // POST /login
-func LoginUser(c *gin.Context) {
+func (h *Handler) LoginUser(c *gin.Context) {
 	type LoginInput struct {
 		Email    string `json:"email" binding:"required,email"`
 		Password string `json:"password" binding:"required"`
@@ -58,48 +127,72 @@ func LoginUser(c *gin.Context) {
 		return
 	}
 
-	var user models.User
+	// Throttle guessing at one account, whichever addresses the guesses come from
+	account := "login:" + strings.ToLower(input.Email)
+	if !h.allow(c, account, loginAccountLimit) {
+		return
+	}
+
 	// Get the user by email from DB
-	query := `SELECT id, username, email, password, created_at FROM users WHERE email=$1`
-	err := db.QueryRow(query, input.Email).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CreatedAt)
+	user, err := h.store.GetUserByEmail(c.Request.Context(), input.Email)
+	if err == nil {
+		// Compare hashed password with provided password
+		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password))
+	}
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		h.loginFailed(c, account, "Invalid email or password")
 		return
 	}
+	if err := h.opts.Limiter.ResetFailures(c.Request.Context(), account); err != nil {
+		log.Printf("⚠️  Failed to reset login failures: %v", err)
+	}
 
-	// Compare hashed password with provided password
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+	h.finishLogin(c, user)
+}
+
+// finishLogin applies the checks every sign-in method shares to an identified user,
+// then either starts a session or asks for the second factor
+func (h *Handler) finishLogin(c *gin.Context, user *models.User) {
+	if h.opts.RequireEmailVerification && !user.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Email address is not verified"})
 		return
 	}
 
-	// Create JWT token upon successful login
-	// 1. Define token claims
-	claims := jwt.MapClaims{
-		"user_id": user.ID,
-		"email":   user.Email,
-		"exp":     time.Now().Add(time.Hour * 72).Unix(), // Token expires in 72 hours
+	// With 2FA on, the first factor only earns a token for the second step
+	tf, err := h.store.GetTwoFactor(c.Request.Context(), user.ID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch two-factor settings"})
+		return
+	}
+	if tf != nil && tf.Enabled {
+		h.requireMFA(c, user)
+		return
 	}
 
-	// 2. Create token with signing method and claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	h.completeLogin(c, user)
+}
 
-	// 3. Sign the token using the secret
-	tokenString, err := token.SignedString(jwtSecret)
+// completeLogin starts a session for an authenticated user and writes the login response
+func (h *Handler) completeLogin(c *gin.Context, user *models.User) {
+	// Every login starts a new family of refresh tokens
+	familyID, err := randomToken(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	response, err := h.issueTokens(c.Request.Context(), user, familyID, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	// Return the token and user info in response
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
-		"token":   tokenString,
-		"user": gin.H{
-			"id":       user.ID,
-			"username": user.Username,
-			"email":    user.Email,
-		},
-	})
-}
\ No newline at end of file
+	// Return the tokens and user info in response
+	response["message"] = "Login successful"
+	response["user"] = gin.H{
+		"id":        user.ID,
+		"username":  user.Username,
+		"email":     user.Email,
+		"time_zone": user.TimeZone,
+	}
+	c.JSON(http.StatusOK, response)
+}
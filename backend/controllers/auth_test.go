@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"habit-tracker/backend/models"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loginResponse is the part of a login or refresh response the tests look at
+type loginResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	MFARequired  bool   `json:"mfa_required"`
+	MFAToken     string `json:"mfa_token"`
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	e := newTestEnv(t)
+
+	var user models.User
+	w := e.do("POST", "/users", "", gin.H{"username": "ann", "email": "ann@example.com", "password": testPassword})
+	expect(t, w, http.StatusCreated, &user)
+	if user.ID == 0 || user.Password != "" || user.TimeZone != models.DefaultTimeZone {
+		t.Errorf("registered user = %+v, want an ID, no password and UTC", user)
+	}
+
+	w = e.do("POST", "/login", "", gin.H{"email": "ann@example.com", "password": "not the password"})
+	expect(t, w, http.StatusUnauthorized, nil)
+
+	var login loginResponse
+	w = e.do("POST", "/login", "", gin.H{"email": "ann@example.com", "password": testPassword})
+	expect(t, w, http.StatusOK, &login)
+	expect(t, e.do("GET", "/me", "", nil), http.StatusUnauthorized, nil)
+
+	var profile models.User
+	expect(t, e.do("GET", "/me", login.Token, nil), http.StatusOK, &profile)
+	if profile.ID != user.ID || profile.Password != "" {
+		t.Errorf("profile = %+v, want user %d without password", profile, user.ID)
+	}
+}
+
+func TestRegisterRejectsUnknownTimeZone(t *testing.T) {
+	e := newTestEnv(t)
+
+	w := e.do("POST", "/users", "", gin.H{"username": "ann", "email": "ann@example.com", "password": testPassword, "time_zone": "Mars/Olympus"})
+	expect(t, w, http.StatusBadRequest, nil)
+	if len(e.store.users) != 0 {
+		t.Errorf("%d users were created, want none", len(e.store.users))
+	}
+}
+
+func TestLoginLocksAccountAfterRepeatedFailures(t *testing.T) {
+	e := newTestEnv(t)
+	e.signUp("ann@example.com")
+
+	wrong := gin.H{"email": "ann@example.com", "password": "not the password"}
+	for i := 1; i < loginBackoff.Threshold; i++ {
+		expect(t, e.do("POST", "/login", "", wrong), http.StatusUnauthorized, nil)
+	}
+	expect(t, e.do("POST", "/login", "", wrong), http.StatusTooManyRequests, nil)
+
+	// Locked out, even with the right password
+	w := e.do("POST", "/login", "", gin.H{"email": "ann@example.com", "password": testPassword})
+	expect(t, w, http.StatusTooManyRequests, nil)
+}
@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/store"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBackfillEntries caps how many dates a single backfill request may cover
+const maxBackfillEntries = 5000
+
+// Outcome of each date in a backfill
+const (
+	backfillInserted       = "inserted"
+	backfillDuplicate      = "duplicate"
+	backfillRejectedFuture = "rejected_future"
+)
+
+// backfillGroup selects dates of one habit, either listed or as an inclusive range,
+// that all receive the same value and note
+type backfillGroup struct {
+	HabitID int      `json:"habit_id" binding:"required"`
+	Dates   []string `json:"dates"`
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Value   *float64 `json:"value"`
+	Note    string   `json:"note"`
+}
+
+// backfillResult reports what happened to one date
+type backfillResult struct {
+	HabitID int    `json:"habit_id"`
+	Date    string `json:"date"`
+	Status  string `json:"status"`
+}
+
+// POST /habits/backfill
+func (h *Handler) BackfillCompletions(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Habits []backfillGroup `json:"habits" binding:"required,min=1,dive"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	loc, err := h.userLocation(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user"})
+		return
+	}
+	today := h.today(loc)
+	earliest := earliestCompletion(today)
+
+	habits := make(map[int]*models.Habit)
+	var entries []models.CompletionEntry
+	var results []backfillResult
+	for _, group := range input.Habits {
+		habit, found := habits[group.HabitID]
+		if !found {
+			habit, err = h.store.GetHabit(c.Request.Context(), group.HabitID, userID)
+			if errors.Is(err, store.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Habit %d not found", group.HabitID)})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch habit"})
+				return
+			}
+			habits[habit.ID] = habit
+		}
+
+		value := 1.0
+		if habit.Type == models.HabitNumeric {
+			if group.Value == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("value is required for numeric habit %d", habit.ID)})
+				return
+			}
+			if *group.Value < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "value cannot be negative"})
+				return
+			}
+			value = *group.Value
+		}
+
+		dates, err := group.dates(maxBackfillEntries - len(results))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		for _, date := range dates {
+			// Unlike future dates, which a range ending today may reach, these are typos
+			if date.Before(earliest) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("habit %d: cannot record completions before %s", habit.ID, earliest.Format("2006-01-02"))})
+				return
+			}
+			result := backfillResult{HabitID: habit.ID, Date: date.Format("2006-01-02")}
+			if date.After(today) {
+				result.Status = backfillRejectedFuture
+			} else {
+				entries = append(entries, models.CompletionEntry{HabitID: habit.ID, Date: date, Value: value, Note: group.Note})
+			}
+			results = append(results, result)
+		}
+	}
+
+	// Step 1: Insert everything in one transaction
+	inserted, err := h.store.AddCompletions(c.Request.Context(), userID, entries)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to backfill completions", "details": err.Error()})
+		return
+	}
+
+	counts := map[string]int{backfillInserted: 0, backfillDuplicate: 0, backfillRejectedFuture: 0}
+	// earliest inserted day of each changed habit
+	changed := make(map[int]time.Time)
+	var order []int
+	next := 0
+	for i := range results {
+		if results[i].Status == "" {
+			results[i].Status = backfillDuplicate
+			if inserted[next] {
+				results[i].Status = backfillInserted
+				date := entries[next].Date
+				habitID := results[i].HabitID
+				if first, ok := changed[habitID]; !ok {
+					order = append(order, habitID)
+					changed[habitID] = date
+				} else if date.Before(first) {
+					changed[habitID] = date
+				}
+			}
+			next++
+		}
+		counts[results[i].Status]++
+	}
+
+	// Step 2: Recalculate each affected habit's streak once
+	var updated []*models.Streak
+	for _, habitID := range order {
+		streak, err := h.recalculateStreaks(c.Request.Context(), habits[habitID], changed[habitID])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update streak", "details": err.Error()})
+			return
+		}
+		if streak != nil {
+			updated = append(updated, streak)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"inserted":        counts[backfillInserted],
+		"duplicate":       counts[backfillDuplicate],
+		"rejected_future": counts[backfillRejectedFuture],
+		"results":         results,
+		"streaks":         updated,
+	})
+}
+
+// dates expands the group into its days, refusing more than limit of them
+func (g backfillGroup) dates(limit int) ([]time.Time, error) {
+	var dates []time.Time
+	for _, s := range g.Dates {
+		date, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q, use YYYY-MM-DD", s)
+		}
+		dates = append(dates, date)
+	}
+
+	if g.From != "" || g.To != "" {
+		from, err := time.Parse("2006-01-02", g.From)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from date %q, use YYYY-MM-DD", g.From)
+		}
+		to, err := time.Parse("2006-01-02", g.To)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to date %q, use YYYY-MM-DD", g.To)
+		}
+		if to.Before(from) {
+			return nil, errors.New("to must not be before from")
+		}
+		for d := from; !d.After(to) && len(dates) <= limit; d = d.AddDate(0, 0, 1) {
+			dates = append(dates, d)
+		}
+	}
+
+	if len(dates) == 0 {
+		return nil, fmt.Errorf("habit %d: give dates or a from/to range", g.HabitID)
+	}
+	if len(dates) > limit {
+		return nil, fmt.Errorf("a backfill may cover at most %d dates", maxBackfillEntries)
+	}
+	return dates, nil
+}
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"habit-tracker/backend/models"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBackfillReportsEachDate(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Read"})
+	e.complete(token, habit.ID, day(-2))
+
+	body := gin.H{"habits": []gin.H{
+		{"habit_id": habit.ID, "dates": []string{day(-3), day(-2)}},
+		{"habit_id": habit.ID, "from": day(-1), "to": day(1), "note": "caught up"},
+	}}
+	var result struct {
+		Inserted       int              `json:"inserted"`
+		Duplicate      int              `json:"duplicate"`
+		RejectedFuture int              `json:"rejected_future"`
+		Results        []backfillResult `json:"results"`
+		Streaks        []models.Streak  `json:"streaks"`
+	}
+	expect(t, e.do("POST", "/habits/backfill", token, body), http.StatusOK, &result)
+
+	if result.Inserted != 3 || result.Duplicate != 1 || result.RejectedFuture != 1 {
+		t.Errorf("counts = %d inserted, %d duplicate, %d future; want 3, 1, 1", result.Inserted, result.Duplicate, result.RejectedFuture)
+	}
+	want := []string{backfillInserted, backfillDuplicate, backfillInserted, backfillInserted, backfillRejectedFuture}
+	if len(result.Results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(result.Results), len(want))
+	}
+	for i, r := range result.Results {
+		if r.Status != want[i] {
+			t.Errorf("%s: %s, want %s", r.Date, r.Status, want[i])
+		}
+	}
+	// The streak is recomputed once, over everything inserted
+	if len(result.Streaks) != 1 || result.Streaks[0].CurrentStreak != 4 {
+		t.Errorf("streaks = %+v, want one of 4 days", result.Streaks)
+	}
+}
+
+func TestBackfillRejectsWholeRequest(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Read"})
+	numeric := e.createHabit(token, gin.H{"title": "Water", "type": "numeric", "target": 8})
+
+	tests := map[string]gin.H{
+		"bad date":          {"habit_id": habit.ID, "dates": []string{"16/10/2026"}},
+		"reversed range":    {"habit_id": habit.ID, "from": day(-1), "to": day(-7)},
+		"no dates":          {"habit_id": habit.ID},
+		"numeric, no value": {"habit_id": numeric.ID, "dates": []string{day(-1)}},
+		"past the lookback": {"habit_id": habit.ID, "dates": []string{"1926-10-17"}},
+	}
+	for name, group := range tests {
+		t.Run(name, func(t *testing.T) {
+			body := gin.H{"habits": []gin.H{{"habit_id": habit.ID, "dates": []string{day(-2)}}, group}}
+			expect(t, e.do("POST", "/habits/backfill", token, body), http.StatusBadRequest, nil)
+		})
+	}
+	body := gin.H{"habits": []gin.H{{"habit_id": habit.ID, "dates": []string{day(-2)}}, {"habit_id": 999, "dates": []string{day(-1)}}}}
+	expect(t, e.do("POST", "/habits/backfill", token, body), http.StatusNotFound, nil)
+
+	if len(e.store.completions) != 0 {
+		t.Errorf("%d completions were recorded, want none", len(e.store.completions))
+	}
+}
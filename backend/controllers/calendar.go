@@ -0,0 +1,276 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/store"
+	"habit-tracker/backend/streaks"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultCalendarDays is a year, the span of a GitHub-style heatmap
+	defaultCalendarDays = 365
+	maxCalendarDays     = 2 * 366
+	// maxIntensity is the darkest heatmap bucket; 0 means nothing was recorded
+	maxIntensity = 4
+)
+
+// calendarDay is one cell of a habit's calendar
+type calendarDay struct {
+	Date      string            `json:"date"`
+	Status    streaks.DayStatus `json:"status"`
+	Value     float64           `json:"value"`
+	Intensity int               `json:"intensity"`
+}
+
+// GET /habits/:id/calendar?from=&to=
+func (h *Handler) GetHabitCalendar(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	habitID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid habit ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	habit, err := h.store.GetHabit(ctx, habitID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch habit"})
+		return
+	}
+
+	loc, err := h.userLocation(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	from, to, ok := h.calendarRange(c, loc)
+	if !ok {
+		return
+	}
+
+	days, err := h.habitCalendar(ctx, habit, loc, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch completions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"habit_id": habit.ID,
+		"title":    habit.Title,
+		"kind":     habit.Kind,
+		"from":     from.Format("2006-01-02"),
+		"to":       to.Format("2006-01-02"),
+		"days":     days,
+	})
+}
+
+// GET /habits/calendar?from=&to=
+func (h *Handler) GetCalendar(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	loc, err := h.userLocation(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	from, to, ok := h.calendarRange(c, loc)
+	if !ok {
+		return
+	}
+
+	habits, err := h.store.ListHabits(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch habits"})
+		return
+	}
+
+	// totals[i] counts the habits done and due on day i of the range
+	totals := make([]struct{ done, due int }, int(to.Sub(from).Hours()/24)+1)
+	perHabit := []gin.H{}
+	for i := range habits {
+		habit := &habits[i]
+		days, err := h.habitCalendar(ctx, habit, loc, from, to)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch completions"})
+			return
+		}
+		for j, day := range days {
+			switch day.Status {
+			case streaks.StatusDone:
+				totals[j].done++
+				totals[j].due++
+			case streaks.StatusMissed:
+				totals[j].due++
+			}
+		}
+		perHabit = append(perHabit, gin.H{
+			"habit_id": habit.ID,
+			"title":    habit.Title,
+			"kind":     habit.Kind,
+			"days":     days,
+		})
+	}
+
+	// Each day's intensity is the share of the habits due that day that were done
+	days := make([]gin.H, len(totals))
+	for i, t := range totals {
+		intensity := 0
+		if t.due > 0 && t.done > 0 {
+			intensity = max(1, int(math.Round(float64(t.done)/float64(t.due)*maxIntensity)))
+		}
+		days[i] = gin.H{
+			"date":      from.AddDate(0, 0, i).Format("2006-01-02"),
+			"done":      t.done,
+			"due":       t.due,
+			"intensity": intensity,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":   from.Format("2006-01-02"),
+		"to":     to.Format("2006-01-02"),
+		"days":   days,
+		"habits": perHabit,
+	})
+}
+
+// calendarRange reads the from and to query parameters, defaulting to the year up
+// to today in loc. It writes a 400 response and returns false if they are invalid.
+func (h *Handler) calendarRange(c *gin.Context, loc *time.Location) (time.Time, time.Time, bool) {
+	parse := func(name string) (time.Time, bool) {
+		value := c.Query(name)
+		if value == "" {
+			return time.Time{}, true
+		}
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s date. Use YYYY-MM-DD", name)})
+			return time.Time{}, false
+		}
+		return date, true
+	}
+
+	from, ok := parse("from")
+	if !ok {
+		return from, from, false
+	}
+	to, ok := parse("to")
+	if !ok {
+		return from, to, false
+	}
+
+	switch {
+	case from.IsZero() && to.IsZero():
+		to = h.today(loc)
+		from = to.AddDate(0, 0, 1-defaultCalendarDays)
+	case to.IsZero():
+		to = from.AddDate(0, 0, defaultCalendarDays-1)
+	case from.IsZero():
+		from = to.AddDate(0, 0, 1-defaultCalendarDays)
+	}
+
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return from, to, false
+	}
+	if days := int(to.Sub(from).Hours()/24) + 1; days > maxCalendarDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Range is limited to %d days", maxCalendarDays)})
+		return from, to, false
+	}
+	return from, to, true
+}
+
+// habitCalendar returns one entry per day from from to to for habit
+func (h *Handler) habitCalendar(ctx context.Context, habit *models.Habit, loc *time.Location, from, to time.Time) ([]calendarDay, error) {
+	in, byDay, err := h.calendarInput(ctx, habit, loc)
+	if err != nil {
+		return nil, err
+	}
+	statuses := streaks.Calendar(in, from, to)
+
+	days := make([]calendarDay, len(statuses))
+	for i, status := range statuses {
+		day := from.AddDate(0, 0, i)
+		value := byDay[day]
+		days[i] = calendarDay{
+			Date:      day.Format("2006-01-02"),
+			Status:    status,
+			Value:     value,
+			Intensity: intensity(habit, status, value),
+		}
+	}
+	return days, nil
+}
+
+// calendarInput returns the streak engine input judging habit day by day, with the
+// days that met the target as its dates, and the recorded value of every day
+func (h *Handler) calendarInput(ctx context.Context, habit *models.Habit, loc *time.Location) (streaks.Input, map[time.Time]float64, error) {
+	values, err := h.store.DailyValues(ctx, habit.ID, habit.UserID)
+	if err != nil {
+		return streaks.Input{}, nil, err
+	}
+
+	// Tracking starts on creation, or on the first recorded day if that is earlier,
+	// even when that day's value fell short of the target
+	start := habit.CreatedAt
+	if len(values) > 0 {
+		d := values[0].Date
+		if first := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, loc); first.Before(start) {
+			start = first
+		}
+	}
+
+	var dates []time.Time
+	byDay := make(map[time.Time]float64, len(values))
+	for _, dv := range values {
+		byDay[streaks.Day(dv.Date)] = dv.Value
+		if habit.MeetsTarget(dv.Value) {
+			dates = append(dates, dv.Date)
+		}
+	}
+
+	return streaks.Input{
+		Dates:    dates,
+		Kind:     habit.Kind,
+		Schedule: habit.Schedule,
+		Start:    start,
+		Location: loc,
+		Clock:    h.clock,
+	}, byDay, nil
+}
+
+// intensity buckets a day from 0 to maxIntensity: a done day is always the darkest,
+// partial progress on a numeric habit scales with how close it came to the target
+func intensity(habit *models.Habit, status streaks.DayStatus, value float64) int {
+	if status == streaks.StatusDone {
+		return maxIntensity
+	}
+	if habit.Type != models.HabitNumeric || habit.Comparison != models.AtLeast || value <= 0 {
+		return 0
+	}
+	ratio := value / habit.Target
+	return min(maxIntensity-1, max(1, int(math.Ceil(ratio*maxIntensity))))
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"habit-tracker/backend/streaks"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHabitCalendar(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Read"})
+	e.backdate(habit.ID, testNow.AddDate(0, 0, -4))
+	e.complete(token, habit.ID, day(-3), day(-1))
+
+	var calendar struct {
+		Days []calendarDay `json:"days"`
+	}
+	expect(t, e.do("GET", habitPath(habit.ID, "/calendar?from="+day(-5)+"&to="+day(1)), token, nil), http.StatusOK, &calendar)
+
+	want := []streaks.DayStatus{
+		streaks.StatusNotScheduled, // before the habit was created
+		streaks.StatusNotScheduled, // before it was first done, as for streaks
+		streaks.StatusDone,
+		streaks.StatusMissed,
+		streaks.StatusDone,
+		streaks.StatusFuture, // today, not done yet
+		streaks.StatusFuture,
+	}
+	if len(calendar.Days) != len(want) {
+		t.Fatalf("got %d days, want %d", len(calendar.Days), len(want))
+	}
+	for i, day := range calendar.Days {
+		if day.Status != want[i] {
+			t.Errorf("%s: %s, want %s", day.Date, day.Status, want[i])
+		}
+	}
+}
+
+func TestCalendarOfAllHabits(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	read := e.createHabit(token, gin.H{"title": "Read"})
+	run := e.createHabit(token, gin.H{"title": "Run"})
+	e.backdate(read.ID, testNow.AddDate(0, 0, -3))
+	e.backdate(run.ID, testNow.AddDate(0, 0, -3))
+	e.complete(token, read.ID, day(-2), day(-1))
+	e.complete(token, run.ID, day(-3))
+
+	var calendar struct {
+		Days []struct {
+			Date      string `json:"date"`
+			Done      int    `json:"done"`
+			Due       int    `json:"due"`
+			Intensity int    `json:"intensity"`
+		} `json:"days"`
+		Habits []gin.H `json:"habits"`
+	}
+	expect(t, e.do("GET", "/habits/calendar?from="+day(-2)+"&to="+day(-1), token, nil), http.StatusOK, &calendar)
+
+	if len(calendar.Habits) != 2 || len(calendar.Days) != 2 {
+		t.Fatalf("got %d habits and %d days, want 2 of each", len(calendar.Habits), len(calendar.Days))
+	}
+	for _, day := range calendar.Days {
+		if day.Done != 1 || day.Due != 2 || day.Intensity != maxIntensity/2 {
+			t.Errorf("%s: %+v, want half of 2 habits done", day.Date, day)
+		}
+	}
+
+	expect(t, e.do("GET", "/habits/calendar?from="+day(-1)+"&to="+day(-2), token, nil), http.StatusBadRequest, nil)
+	expect(t, e.do("GET", "/habits/calendar?from="+day(-maxCalendarDays)+"&to="+day(0), token, nil), http.StatusBadRequest, nil)
+}
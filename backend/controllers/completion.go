@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"errors"
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PUT /habits/:id/completions/:date
+func (h *Handler) UpdateCompletion(c *gin.Context) {
+	habit, date, ok := h.completionTarget(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Value *float64 `json:"value"`
+		Date  string   `json:"date"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Boolean habits always record 1, numeric ones need the corrected total
+	value := 1.0
+	if habit.Type == models.HabitNumeric {
+		if input.Value == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "value is required for numeric habits"})
+			return
+		}
+		if *input.Value < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "value cannot be negative"})
+			return
+		}
+		value = *input.Value
+	}
+
+	// Optionally move the entry to another day
+	newDate := date
+	if input.Date != "" {
+		parsedDate, err := time.Parse("2006-01-02", input.Date)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+			return
+		}
+		loc, err := h.userLocation(c.Request.Context(), habit.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user"})
+			return
+		}
+		if !checkCompletionDate(c, parsedDate, h.today(loc)) {
+			return
+		}
+		newDate = parsedDate
+	}
+
+	err := h.store.UpdateCompletion(c.Request.Context(), habit.ID, habit.UserID, date, newDate, value)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No completion recorded for this date"})
+		return
+	}
+	if errors.Is(err, store.ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A completion is already recorded for the new date"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update completion", "details": err.Error()})
+		return
+	}
+
+	since := date
+	if newDate.Before(since) {
+		since = newDate
+	}
+	streak, err := h.recalculateStreaks(c.Request.Context(), habit, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update streak", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Completion updated with streak updated",
+		"date":      newDate.Format("2006-01-02"),
+		"value":     value,
+		"completed": habit.MeetsTarget(value),
+		"streak":    streak,
+	})
+}
+
+// DELETE /habits/:id/completions/:date
+func (h *Handler) DeleteCompletion(c *gin.Context) {
+	habit, date, ok := h.completionTarget(c)
+	if !ok {
+		return
+	}
+
+	err := h.store.DeleteCompletion(c.Request.Context(), habit.ID, habit.UserID, date)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No completion recorded for this date"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete completion", "details": err.Error()})
+		return
+	}
+
+	// Removing the last completion also removes the streak
+	streak, err := h.recalculateStreaks(c.Request.Context(), habit, date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update streak", "details": err.Error()})
+		return
+	}
+
+	message := "Completion removed with streak updated"
+	if habit.Kind == models.HabitQuit {
+		message = "Slip removed with streak updated"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"date":    date.Format("2006-01-02"),
+		"streak":  streak,
+	})
+}
+
+// completionTarget resolves the habit and date of a /habits/:id/completions/:date
+// request, writing the error response and returning false if either is invalid
+func (h *Handler) completionTarget(c *gin.Context) (*models.Habit, time.Time, bool) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, time.Time{}, false
+	}
+
+	habitID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid habit ID"})
+		return nil, time.Time{}, false
+	}
+
+	date, err := time.Parse("2006-01-02", c.Param("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+		return nil, time.Time{}, false
+	}
+
+	habit, err := h.store.GetHabit(c.Request.Context(), habitID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found"})
+		return nil, time.Time{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch habit"})
+		return nil, time.Time{}, false
+	}
+	return habit, date, true
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"habit-tracker/backend/models"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// completionResponse is the answer to editing or removing a completion
+type completionResponse struct {
+	Date   string         `json:"date"`
+	Streak *models.Streak `json:"streak"`
+}
+
+func TestUpdateCompletionMovesDay(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Read"})
+	e.complete(token, habit.ID, day(-2), day(-1), day(0))
+
+	var moved completionResponse
+	expect(t, e.do("PUT", habitPath(habit.ID, "/completions/"+day(-1)), token, gin.H{"date": day(-4)}), http.StatusOK, &moved)
+	if moved.Date != day(-4) || moved.Streak == nil || moved.Streak.CurrentStreak != 1 || moved.Streak.LongestStreak != 1 {
+		t.Errorf("moved = %+v, want %s and streaks broken by the gap it leaves", moved, day(-4))
+	}
+
+	// Moving onto a day that is already recorded, from one that is not, into the future
+	// or past the lookback
+	expect(t, e.do("PUT", habitPath(habit.ID, "/completions/"+day(-4)), token, gin.H{"date": day(-2)}), http.StatusConflict, nil)
+	expect(t, e.do("PUT", habitPath(habit.ID, "/completions/"+day(-1)), token, gin.H{"date": day(-3)}), http.StatusNotFound, nil)
+	expect(t, e.do("PUT", habitPath(habit.ID, "/completions/"+day(-4)), token, gin.H{"date": day(1)}), http.StatusBadRequest, nil)
+	expect(t, e.do("PUT", habitPath(habit.ID, "/completions/"+day(-4)), token, gin.H{"date": "1926-10-17"}), http.StatusBadRequest, nil)
+	expect(t, e.do("POST", habitPath(habit.ID, "?date=1926-10-17"), token, nil), http.StatusBadRequest, nil)
+}
+
+func TestUpdateCompletionCorrectsNumericValue(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Water", "type": "numeric", "target": 8})
+	expect(t, e.do("POST", habitPath(habit.ID, ""), token, gin.H{"value": 3}), http.StatusOK, nil)
+
+	path := habitPath(habit.ID, "/completions/"+day(0))
+	expect(t, e.do("PUT", path, token, gin.H{}), http.StatusBadRequest, nil)
+
+	var updated struct {
+		Value     float64 `json:"value"`
+		Completed bool    `json:"completed"`
+	}
+	expect(t, e.do("PUT", path, token, gin.H{"value": 8}), http.StatusOK, &updated)
+	if updated.Value != 8 || !updated.Completed {
+		t.Errorf("updated = %+v, want 8 meeting the target", updated)
+	}
+}
+
+func TestDeleteCompletion(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Read"})
+	e.complete(token, habit.ID, day(0))
+
+	var deleted completionResponse
+	expect(t, e.do("DELETE", habitPath(habit.ID, "/completions/"+day(0)), token, nil), http.StatusOK, &deleted)
+	if deleted.Streak != nil {
+		t.Errorf("streak = %+v, want none after removing the only completion", deleted.Streak)
+	}
+	if _, ok := e.store.streaks[habit.ID]; ok {
+		t.Error("the streak record was not removed")
+	}
+
+	expect(t, e.do("DELETE", habitPath(habit.ID, "/completions/"+day(0)), token, nil), http.StatusNotFound, nil)
+	expect(t, e.do("DELETE", habitPath(habit.ID, "/completions/17-10-2026"), token, nil), http.StatusBadRequest, nil)
+}
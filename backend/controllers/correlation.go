@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"fmt"
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/stats"
+	"habit-tracker/backend/streaks"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultCorrelationMinDays drops pairs tracked together for too short to tell anything
+	defaultCorrelationMinDays = 14
+	defaultCorrelationLimit   = 5
+	maxCorrelationLimit       = 50
+)
+
+// habitPair is the correlation of two habits
+type habitPair struct {
+	a, b        *models.Habit
+	correlation stats.Correlation
+	phi         float64
+}
+
+// GET /habits/correlations?from=&to=&min_days=&limit=
+func (h *Handler) GetCorrelations(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	minDays, ok := positiveQuery(c, "min_days", defaultCorrelationMinDays, maxCalendarDays)
+	if !ok {
+		return
+	}
+	limit, ok := positiveQuery(c, "limit", defaultCorrelationLimit, maxCorrelationLimit)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	loc, err := h.userLocation(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	from, to, ok := h.calendarRange(c, loc)
+	if !ok {
+		return
+	}
+	// Today is not over, so a habit not done yet would look like a miss
+	if yesterday := h.today(loc).AddDate(0, 0, -1); to.After(yesterday) {
+		to = yesterday
+	}
+
+	habits, err := h.store.ListHabits(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch habits"})
+		return
+	}
+
+	// A day only says something about a pair if both habits were due on it,
+	// as the streak engine schedules them
+	days := max(int(to.Sub(from).Hours()/24)+1, 0) // none if the range starts today
+	done := make([][]bool, len(habits))
+	due := make([][]bool, len(habits))
+	for i := range habits {
+		in, _, err := h.calendarInput(ctx, &habits[i], loc)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch completions"})
+			return
+		}
+		due[i] = streaks.Due(in, from, to)
+		done[i] = make([]bool, days)
+		for _, d := range in.Dates {
+			if d = streaks.Day(d); !d.Before(from) && !d.After(to) {
+				done[i][int(d.Sub(from).Hours()/24)] = true
+			}
+		}
+	}
+
+	var positive, negative []habitPair
+	considered := 0
+	for i := range habits {
+		for j := i + 1; j < len(habits); j++ {
+			correlation := stats.Correlate(done[i], done[j], func(k int) bool { return due[i][k] && due[j][k] })
+			phi := correlation.Phi()
+			if correlation.Days < minDays || math.IsNaN(phi) {
+				continue
+			}
+			considered++
+
+			pair := habitPair{a: &habits[i], b: &habits[j], correlation: correlation, phi: phi}
+			switch {
+			case phi > 0:
+				positive = append(positive, pair)
+			case phi < 0:
+				negative = append(negative, pair)
+			}
+		}
+	}
+
+	// Strongest first, the larger sample breaking ties
+	sort.SliceStable(positive, func(i, j int) bool {
+		if positive[i].phi != positive[j].phi {
+			return positive[i].phi > positive[j].phi
+		}
+		return positive[i].correlation.Days > positive[j].correlation.Days
+	})
+	sort.SliceStable(negative, func(i, j int) bool {
+		if negative[i].phi != negative[j].phi {
+			return negative[i].phi < negative[j].phi
+		}
+		return negative[i].correlation.Days > negative[j].correlation.Days
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":             from.Format("2006-01-02"),
+		"to":               to.Format("2006-01-02"),
+		"min_days":         minDays,
+		"pairs_considered": considered,
+		"positive":         pairsResponse(positive, limit),
+		"negative":         pairsResponse(negative, limit),
+	})
+}
+
+// pairsResponse writes up to limit pairs
+func pairsResponse(pairs []habitPair, limit int) []gin.H {
+	result := []gin.H{}
+	for _, p := range pairs[:min(limit, len(pairs))] {
+		result = append(result, gin.H{
+			"habit_a":     gin.H{"habit_id": p.a.ID, "title": p.a.Title, "kind": p.a.Kind},
+			"habit_b":     gin.H{"habit_id": p.b.ID, "title": p.b.Title, "kind": p.b.Kind},
+			"correlation": p.correlation,
+		})
+	}
+	return result
+}
+
+// positiveQuery reads an integer query parameter from 1 to max, defaulting to def.
+// It writes a 400 response and returns false if the value is invalid.
+func positiveQuery(c *gin.Context, name string, def, max int) (int, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > max {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be an integer from 1 to %d", name, max)})
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"habit-tracker/backend/models"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCorrelations(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	created := testNow.AddDate(0, 0, -20)
+	read := e.createHabit(token, gin.H{"title": "Read"})
+	tea := e.createHabit(token, gin.H{"title": "Tea"})
+	scroll := e.createHabit(token, gin.H{"title": "Doomscroll"})
+	for _, habit := range []models.Habit{read, tea, scroll} {
+		e.backdate(habit.ID, created)
+	}
+
+	// Tea goes with reading, scrolling replaces it
+	var reading, other []string
+	for d := created; d.Before(testNow.AddDate(0, 0, -1)); d = d.AddDate(0, 0, 1) {
+		if d.Day()%2 == 0 {
+			reading = append(reading, d.Format("2006-01-02"))
+		} else {
+			other = append(other, d.Format("2006-01-02"))
+		}
+	}
+	body := gin.H{"habits": []gin.H{
+		{"habit_id": read.ID, "dates": reading},
+		{"habit_id": tea.ID, "dates": reading},
+		{"habit_id": scroll.ID, "dates": other},
+	}}
+	expect(t, e.do("POST", "/habits/backfill", token, body), http.StatusOK, nil)
+
+	type side struct {
+		HabitID int `json:"habit_id"`
+	}
+	type pair struct {
+		HabitA side `json:"habit_a"`
+		HabitB side `json:"habit_b"`
+	}
+	var correlations struct {
+		PairsConsidered int    `json:"pairs_considered"`
+		Positive        []pair `json:"positive"`
+		Negative        []pair `json:"negative"`
+	}
+	expect(t, e.do("GET", "/habits/correlations", token, nil), http.StatusOK, &correlations)
+
+	if correlations.PairsConsidered != 3 || len(correlations.Positive) != 1 || len(correlations.Negative) != 2 {
+		t.Fatalf("correlations = %+v, want 3 pairs, 1 positive", correlations)
+	}
+	if p := correlations.Positive[0]; p.HabitA.HabitID != read.ID || p.HabitB.HabitID != tea.ID {
+		t.Errorf("positive pair = %+v, want reading and tea", p)
+	}
+
+	// Too few days tracked together to say anything
+	expect(t, e.do("GET", "/habits/correlations?min_days=30", token, nil), http.StatusOK, &correlations)
+	if correlations.PairsConsidered != 0 {
+		t.Errorf("%d pairs considered with min_days=30, want none", correlations.PairsConsidered)
+	}
+	expect(t, e.do("GET", "/habits/correlations?limit=0", token, nil), http.StatusBadRequest, nil)
+}
@@ -0,0 +1,887 @@
+package controllers
+
+import (
+	"cmp"
+	"context"
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/store"
+	"slices"
+	"sync"
+	"time"
+)
+
+var _ store.Store = (*fakeStore)(nil)
+
+// fakeStore is an in-memory store.Store for handler tests. It mirrors what the
+// SQL store does closely enough for the handlers not to tell the difference:
+// days are compared as YYYY-MM-DD and missing rows turn into ErrNotFound.
+// Everything handed out is a copy.
+type fakeStore struct {
+	mu     sync.Mutex
+	nextID int
+
+	users         map[int]*models.User
+	userTokens    []*models.UserToken
+	identities    map[[2]string]int // issuer and subject to user
+	habits        map[int]*models.Habit
+	completions   map[completionKey]*fakeCompletion
+	streaks       map[int]models.Streak       // by habit
+	strength      map[int]map[string]float64  // by habit, then day
+	recomputes    map[recomputeKey]*time.Time // lease, nil once finished
+	sessions      map[int]*models.Session
+	revoked       map[string]time.Time // access token IDs until their expiry
+	accessTokens  map[int]*models.AccessToken
+	twoFactor     map[int]*models.TwoFactor
+	recoveryCodes map[int]map[string]bool // by user, then hash to whether it was used
+}
+
+type completionKey struct {
+	habitID int
+	date    string
+}
+
+type recomputeKey struct {
+	userID int
+	day    string
+}
+
+type fakeCompletion struct {
+	id      int
+	habitID int
+	userID  int
+	date    time.Time
+	value   float64
+	note    string
+	// completedAt is nil for completions recorded without a check-in time
+	completedAt *time.Time
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		users:         make(map[int]*models.User),
+		identities:    make(map[[2]string]int),
+		habits:        make(map[int]*models.Habit),
+		completions:   make(map[completionKey]*fakeCompletion),
+		streaks:       make(map[int]models.Streak),
+		strength:      make(map[int]map[string]float64),
+		recomputes:    make(map[recomputeKey]*time.Time),
+		sessions:      make(map[int]*models.Session),
+		revoked:       make(map[string]time.Time),
+		accessTokens:  make(map[int]*models.AccessToken),
+		twoFactor:     make(map[int]*models.TwoFactor),
+		recoveryCodes: make(map[int]map[string]bool),
+	}
+}
+
+func (s *fakeStore) id() int {
+	s.nextID++
+	return s.nextID
+}
+
+// dateKey formats a day the way the SQL store binds DATE columns
+func dateKey(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+// parseDay reads a day back as the driver scans DATE columns, at midnight UTC
+func parseDay(key string) time.Time {
+	d, _ := time.Parse("2006-01-02", key)
+	return d
+}
+
+func (s *fakeStore) CreateUser(ctx context.Context, user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.createUser(user)
+}
+
+func (s *fakeStore) createUser(user *models.User) error {
+	for _, u := range s.users {
+		if u.Email == user.Email {
+			return store.ErrConflict
+		}
+	}
+	user.ID = s.id()
+	u := *user
+	s.users[u.ID] = &u
+	return nil
+}
+
+func (s *fakeStore) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.Email == email {
+			user := *u
+			return &user, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *fakeStore) GetUserByID(ctx context.Context, userID int) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	user := *u
+	return &user, nil
+}
+
+func (s *fakeStore) ListUsers(ctx context.Context) ([]models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var users []models.User
+	for _, u := range s.users {
+		users = append(users, *u)
+	}
+	slices.SortFunc(users, func(a, b models.User) int { return cmp.Compare(a.ID, b.ID) })
+	return users, nil
+}
+
+func (s *fakeStore) UpdateUser(ctx context.Context, user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[user.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	u.Username, u.TimeZone = user.Username, user.TimeZone
+	*user = *u
+	return nil
+}
+
+func (s *fakeStore) UpdatePassword(ctx context.Context, userID int, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	u.Password = passwordHash
+	return nil
+}
+
+func (s *fakeStore) SetEmailVerified(ctx context.Context, userID int, verified bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	u.EmailVerified = verified
+	return nil
+}
+
+func (s *fakeStore) UpdateEmail(ctx context.Context, userID int, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.Email == email && u.ID != userID {
+			return store.ErrConflict
+		}
+	}
+	u, ok := s.users[userID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	u.Email, u.EmailVerified = email, true
+	return nil
+}
+
+func (s *fakeStore) DeleteUser(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[userID]; !ok {
+		return store.ErrNotFound
+	}
+	for id, habit := range s.habits {
+		if habit.UserID == userID {
+			s.deleteHabit(id)
+		}
+	}
+	delete(s.users, userID)
+	return nil
+}
+
+func (s *fakeStore) CreateUserToken(ctx context.Context, token *models.UserToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token.ID = s.id()
+	t := *token
+	s.userTokens = append(s.userTokens, &t)
+	return nil
+}
+
+func (s *fakeStore) ConsumeUserToken(ctx context.Context, purpose, tokenHash string) (*models.UserToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, t := range s.userTokens {
+		if t.Purpose == purpose && t.TokenHash == tokenHash && t.UsedAt == nil && t.ExpiresAt.After(now) {
+			t.UsedAt = &now
+			token := *t
+			return &token, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *fakeStore) ListHabits(ctx context.Context, userID int) ([]models.Habit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.userHabits(userID), nil
+}
+
+// userHabits returns copies of the user's habits, oldest first
+func (s *fakeStore) userHabits(userID int) []models.Habit {
+	var habits []models.Habit
+	for _, habit := range s.habits {
+		if habit.UserID == userID {
+			habits = append(habits, *habit)
+		}
+	}
+	slices.SortFunc(habits, func(a, b models.Habit) int { return cmp.Compare(a.ID, b.ID) })
+	return habits
+}
+
+func (s *fakeStore) GetHabit(ctx context.Context, habitID, userID int) (*models.Habit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	habit, ok := s.habits[habitID]
+	if !ok || habit.UserID != userID {
+		return nil, store.ErrNotFound
+	}
+	h := *habit
+	return &h, nil
+}
+
+func (s *fakeStore) CreateHabit(ctx context.Context, habit *models.Habit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	habit.ID = s.id()
+	h := *habit
+	s.habits[h.ID] = &h
+	return nil
+}
+
+func (s *fakeStore) UpdateHabit(ctx context.Context, habit *models.Habit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.habits[habit.ID]
+	if !ok || existing.UserID != habit.UserID {
+		return store.ErrNotFound
+	}
+	habit.CreatedAt = existing.CreatedAt
+	h := *habit
+	s.habits[h.ID] = &h
+	return nil
+}
+
+func (s *fakeStore) DeleteHabit(ctx context.Context, habitID, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	habit, ok := s.habits[habitID]
+	if !ok || habit.UserID != userID {
+		return store.ErrNotFound
+	}
+	s.deleteHabit(habitID)
+	return nil
+}
+
+// deleteHabit removes a habit with the rows the foreign keys cascade to
+func (s *fakeStore) deleteHabit(habitID int) {
+	for key := range s.completions {
+		if key.habitID == habitID {
+			delete(s.completions, key)
+		}
+	}
+	delete(s.streaks, habitID)
+	delete(s.strength, habitID)
+	delete(s.habits, habitID)
+}
+
+func (s *fakeStore) CountHabits(ctx context.Context, userID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.userHabits(userID)), nil
+}
+
+func (s *fakeStore) AddCompletion(ctx context.Context, habitID, userID int, date, completedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.insertCompletion(habitID, userID, date, 1, "", &completedAt), nil
+}
+
+// insertCompletion adds a completion unless the habit already has one that day
+func (s *fakeStore) insertCompletion(habitID, userID int, date time.Time, value float64, note string, completedAt *time.Time) bool {
+	key := completionKey{habitID, dateKey(date)}
+	if _, exists := s.completions[key]; exists {
+		return false
+	}
+	s.completions[key] = &fakeCompletion{id: s.id(), habitID: habitID, userID: userID, date: parseDay(key.date), value: value, note: note, completedAt: completedAt}
+	return true
+}
+
+func (s *fakeStore) AccumulateCompletion(ctx context.Context, habitID, userID int, date time.Time, value float64, completedAt time.Time) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.insertCompletion(habitID, userID, date, value, "", &completedAt) {
+		return value, nil
+	}
+	hc := s.completions[completionKey{habitID, dateKey(date)}]
+	hc.value += value
+	hc.completedAt = &completedAt
+	return hc.value, nil
+}
+
+func (s *fakeStore) AddCompletions(ctx context.Context, userID int, entries []models.CompletionEntry) ([]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inserted := make([]bool, len(entries))
+	for i, e := range entries {
+		inserted[i] = s.insertCompletion(e.HabitID, userID, e.Date, e.Value, e.Note, nil)
+	}
+	return inserted, nil
+}
+
+func (s *fakeStore) UpdateCompletion(ctx context.Context, habitID, userID int, date, newDate time.Time, value float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	from, to := completionKey{habitID, dateKey(date)}, completionKey{habitID, dateKey(newDate)}
+	if to != from {
+		if _, exists := s.completions[to]; exists {
+			return store.ErrConflict
+		}
+	}
+	hc, ok := s.completions[from]
+	if !ok || hc.userID != userID {
+		return store.ErrNotFound
+	}
+	hc.value = value
+	if to != from {
+		// The check-in was for the old day
+		hc.date, hc.completedAt = parseDay(to.date), nil
+		delete(s.completions, from)
+		s.completions[to] = hc
+	}
+	return nil
+}
+
+func (s *fakeStore) DeleteCompletion(ctx context.Context, habitID, userID int, date time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := completionKey{habitID, dateKey(date)}
+	hc, ok := s.completions[key]
+	if !ok || hc.userID != userID {
+		return store.ErrNotFound
+	}
+	delete(s.completions, key)
+	return nil
+}
+
+// userCompletions returns the user's completions of habitID, or of all their
+// habits if it is 0, together with their habit and oldest first
+func (s *fakeStore) userCompletions(userID, habitID int) ([]*fakeCompletion, map[int]*models.Habit) {
+	var list []*fakeCompletion
+	habits := make(map[int]*models.Habit)
+	for _, hc := range s.completions {
+		habit, ok := s.habits[hc.habitID]
+		if !ok || hc.userID != userID || (habitID != 0 && hc.habitID != habitID) {
+			continue
+		}
+		list = append(list, hc)
+		habits[hc.habitID] = habit
+	}
+	slices.SortFunc(list, func(a, b *fakeCompletion) int {
+		return cmp.Or(a.date.Compare(b.date), cmp.Compare(a.habitID, b.habitID))
+	})
+	return list, habits
+}
+
+func (s *fakeStore) DailyValues(ctx context.Context, habitID, userID int) ([]models.DailyValue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list, _ := s.userCompletions(userID, habitID)
+	var values []models.DailyValue
+	for _, hc := range list {
+		values = append(values, models.DailyValue{Date: hc.date, Value: hc.value, Note: hc.note})
+	}
+	return values, nil
+}
+
+func (s *fakeStore) ListCompletedHabits(ctx context.Context, userID int) ([]models.CompletedHabit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list, habits := s.userCompletions(userID, 0)
+	var completed []models.CompletedHabit
+	for i := len(list) - 1; i >= 0; i-- {
+		hc, habit := list[i], habits[list[i].habitID]
+		if habit.Kind != models.HabitBuild {
+			continue
+		}
+		completed = append(completed, models.CompletedHabit{
+			Completion:  models.Completion{ID: hc.id, HabitID: hc.habitID, UserID: userID, DateCompleted: hc.date},
+			Title:       habit.Title,
+			Description: habit.Description,
+		})
+	}
+	return completed, nil
+}
+
+func (s *fakeStore) CountCompletions(ctx context.Context, userID int, kind models.HabitKind) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list, habits := s.userCompletions(userID, 0)
+	count := 0
+	for _, hc := range list {
+		if habits[hc.habitID].Kind == kind {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (hc *fakeCompletion) between(from, to time.Time) bool {
+	d := dateKey(hc.date)
+	return d >= dateKey(from) && d <= dateKey(to)
+}
+
+func (s *fakeStore) CompletionsByPeriod(ctx context.Context, userID, habitID int, g models.Granularity) ([]models.PeriodCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	type periodKey struct {
+		habitID int
+		period  time.Time
+	}
+	list, habits := s.userCompletions(userID, habitID)
+	byPeriod := make(map[periodKey]*models.PeriodCount)
+	var counts []*models.PeriodCount
+	for _, hc := range list {
+		if habit := habits[hc.habitID]; !habit.MeetsTarget(hc.value) || !habit.Schedule.Covers(hc.date.Weekday()) {
+			continue
+		}
+		key := periodKey{hc.habitID, g.Truncate(hc.date)}
+		pc, ok := byPeriod[key]
+		if !ok {
+			// list is oldest first, so the first completion seen is the earliest
+			pc = &models.PeriodCount{HabitID: key.habitID, Period: key.period, First: hc.date}
+			byPeriod[key] = pc
+			counts = append(counts, pc)
+		}
+		pc.Count++
+	}
+	slices.SortFunc(counts, func(a, b *models.PeriodCount) int {
+		return cmp.Or(cmp.Compare(a.HabitID, b.HabitID), a.Period.Compare(b.Period))
+	})
+	var result []models.PeriodCount
+	for _, pc := range counts {
+		result = append(result, *pc)
+	}
+	return result, nil
+}
+
+func (s *fakeStore) CompletionsBetween(ctx context.Context, userID, habitID int, from, to time.Time) (map[int]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list, habits := s.userCompletions(userID, habitID)
+	counts := make(map[int]int)
+	for _, hc := range list {
+		habit := habits[hc.habitID]
+		if hc.between(from, to) && habit.MeetsTarget(hc.value) && habit.Schedule.Covers(hc.date.Weekday()) {
+			counts[hc.habitID]++
+		}
+	}
+	return counts, nil
+}
+
+func (s *fakeStore) CheckIns(ctx context.Context, userID, habitID int, from, to time.Time) ([]models.CheckIn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list, habits := s.userCompletions(userID, habitID)
+	var checkIns []models.CheckIn
+	for _, hc := range list {
+		if hc.completedAt != nil && hc.between(from, to) && habits[hc.habitID].MeetsTarget(hc.value) {
+			checkIns = append(checkIns, models.CheckIn{HabitID: hc.habitID, Date: hc.date, CompletedAt: *hc.completedAt})
+		}
+	}
+	return checkIns, nil
+}
+
+func (s *fakeStore) SaveStreak(ctx context.Context, streak models.Streak) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if streak.LastCompleted != nil {
+		last := parseDay(dateKey(*streak.LastCompleted))
+		streak.LastCompleted = &last
+	}
+	s.streaks[streak.HabitID] = streak
+	return nil
+}
+
+func (s *fakeStore) DeleteStreak(ctx context.Context, habitID, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if streak, ok := s.streaks[habitID]; ok && streak.UserID == userID {
+		delete(s.streaks, habitID)
+		delete(s.strength, habitID)
+	}
+	return nil
+}
+
+func (s *fakeStore) ListHabitStreaks(ctx context.Context, userID int) ([]models.HabitWithStreak, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var results []models.HabitWithStreak
+	for _, habit := range s.userHabits(userID) {
+		result := models.HabitWithStreak{Habit: habit}
+		if streak, ok := s.streaks[habit.ID]; ok {
+			result.Streak = &streak
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (s *fakeStore) LongestStreak(ctx context.Context, userID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	longest := 0
+	for _, streak := range s.streaks {
+		if streak.UserID == userID {
+			longest = max(longest, streak.LongestStreak)
+		}
+	}
+	return longest, nil
+}
+
+func (s *fakeStore) MostConsistentHabit(ctx context.Context, userID int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var best *models.Streak
+	for _, streak := range s.streaks {
+		if streak.UserID != userID {
+			continue
+		}
+		if best == nil || streak.Strength > best.Strength ||
+			(streak.Strength == best.Strength && streak.CurrentStreak > best.CurrentStreak) {
+			best = &streak
+		}
+	}
+	if best == nil {
+		return "", nil
+	}
+	return s.habits[best.HabitID].Title, nil
+}
+
+func (s *fakeStore) SaveStrength(ctx context.Context, habitID, userID int, keepFrom time.Time, history []models.DayStrength) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	days, ok := s.strength[habitID]
+	if !ok {
+		days = make(map[string]float64)
+		s.strength[habitID] = days
+	}
+	for day := range days {
+		if day < dateKey(keepFrom) {
+			delete(days, day)
+		}
+	}
+	for _, ds := range history {
+		days[dateKey(ds.Date)] = ds.Strength
+	}
+	return nil
+}
+
+func (s *fakeStore) LatestStrengthDay(ctx context.Context, habitID, userID int) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	latest := ""
+	for day := range s.strength[habitID] {
+		latest = max(latest, day)
+	}
+	if latest == "" {
+		return time.Time{}, nil
+	}
+	return parseDay(latest), nil
+}
+
+func (s *fakeStore) StrengthHistory(ctx context.Context, habitID, userID int, from, to time.Time) ([]models.DayStrength, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	history := []models.DayStrength{}
+	for day, strength := range s.strength[habitID] {
+		if day >= dateKey(from) && day <= dateKey(to) {
+			history = append(history, models.DayStrength{Date: parseDay(day), Strength: strength})
+		}
+	}
+	slices.SortFunc(history, func(a, b models.DayStrength) int { return a.Date.Compare(b.Date) })
+	return history, nil
+}
+
+func (s *fakeStore) ClaimStreakRecompute(ctx context.Context, userID int, day, now, leaseUntil time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := recomputeKey{userID, dateKey(day)}
+	if lease, exists := s.recomputes[key]; exists && (lease == nil || !lease.Before(now)) {
+		return false, nil
+	}
+	s.recomputes[key] = &leaseUntil
+	return true, nil
+}
+
+func (s *fakeStore) FinishStreakRecompute(ctx context.Context, userID int, day time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := recomputeKey{userID, dateKey(day)}
+	if _, exists := s.recomputes[key]; exists {
+		s.recomputes[key] = nil
+	}
+	return nil
+}
+
+func (s *fakeStore) CreateSession(ctx context.Context, session *models.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.insertSession(session)
+	return nil
+}
+
+func (s *fakeStore) insertSession(session *models.Session) {
+	session.ID = s.id()
+	sess := *session
+	s.sessions[sess.ID] = &sess
+}
+
+func (s *fakeStore) GetSessionByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sess := range s.sessions {
+		if sess.TokenHash == tokenHash {
+			session := *sess
+			return &session, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *fakeStore) RotateSession(ctx context.Context, oldID int, next *models.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.revokeSessions(func(sess *models.Session) bool { return sess.ID == oldID }) == 0 {
+		return store.ErrConflict
+	}
+	s.insertSession(next)
+	return nil
+}
+
+func (s *fakeStore) RevokeSession(ctx context.Context, sessionID, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokeSessions(func(sess *models.Session) bool { return sess.ID == sessionID && sess.UserID == userID })
+	return nil
+}
+
+func (s *fakeStore) RevokeSessionFamily(ctx context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokeSessions(func(sess *models.Session) bool { return sess.FamilyID == familyID })
+	return nil
+}
+
+func (s *fakeStore) RevokeUserSessions(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokeSessions(func(sess *models.Session) bool { return sess.UserID == userID })
+	return nil
+}
+
+// revokeSessions revokes the active sessions match selects, puts their unexpired
+// access tokens on the revocation list and returns how many it revoked
+func (s *fakeStore) revokeSessions(match func(*models.Session) bool) int {
+	now := time.Now()
+	revoked := 0
+	for _, sess := range s.sessions {
+		if sess.RevokedAt != nil || !match(sess) {
+			continue
+		}
+		if sess.AccessExpiresAt.After(now) {
+			s.revoked[sess.AccessJTI] = sess.AccessExpiresAt
+		}
+		sess.RevokedAt = &now
+		revoked++
+	}
+	return revoked
+}
+
+func (s *fakeStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, revoked := s.revoked[jti]
+	return revoked, nil
+}
+
+func (s *fakeStore) CreateAccessToken(ctx context.Context, token *models.AccessToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token.ID = s.id()
+	t := *token
+	s.accessTokens[t.ID] = &t
+	return nil
+}
+
+func (s *fakeStore) ListAccessTokens(ctx context.Context, userID int) ([]models.AccessToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens := []models.AccessToken{}
+	for _, t := range s.accessTokens {
+		if t.UserID == userID {
+			tokens = append(tokens, *t)
+		}
+	}
+	slices.SortFunc(tokens, func(a, b models.AccessToken) int { return cmp.Compare(b.ID, a.ID) })
+	return tokens, nil
+}
+
+func (s *fakeStore) GetAccessTokenByHash(ctx context.Context, tokenHash string) (*models.AccessToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.accessTokens {
+		if t.TokenHash == tokenHash {
+			token := *t
+			return &token, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *fakeStore) DeleteAccessToken(ctx context.Context, tokenID, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.accessTokens[tokenID]
+	if !ok || t.UserID != userID {
+		return store.ErrNotFound
+	}
+	delete(s.accessTokens, tokenID)
+	return nil
+}
+
+func (s *fakeStore) DeleteUserAccessTokens(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, t := range s.accessTokens {
+		if t.UserID == userID {
+			delete(s.accessTokens, id)
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) TouchAccessToken(ctx context.Context, tokenID int, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.accessTokens[tokenID]; ok {
+		t.LastUsedAt = &usedAt
+	}
+	return nil
+}
+
+func (s *fakeStore) GetTwoFactor(ctx context.Context, userID int) (*models.TwoFactor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tf, ok := s.twoFactor[userID]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	t := *tf
+	return &t, nil
+}
+
+func (s *fakeStore) SaveTwoFactor(ctx context.Context, tf *models.TwoFactor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := *tf
+	s.twoFactor[t.UserID] = &t
+	return nil
+}
+
+func (s *fakeStore) DeleteTwoFactor(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.recoveryCodes, userID)
+	delete(s.twoFactor, userID)
+	return nil
+}
+
+func (s *fakeStore) UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tf, ok := s.twoFactor[userID]
+	if !ok || tf.LastStep >= step {
+		return false, nil
+	}
+	tf.LastStep = step
+	return true, nil
+}
+
+func (s *fakeStore) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	codes := make(map[string]bool, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes[hash] = false
+	}
+	s.recoveryCodes[userID] = codes
+	return nil
+}
+
+func (s *fakeStore) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	used, exists := s.recoveryCodes[userID][codeHash]
+	if !exists || used {
+		return false, nil
+	}
+	s.recoveryCodes[userID][codeHash] = true
+	return true, nil
+}
+
+func (s *fakeStore) GetUserByIdentity(ctx context.Context, issuer, subject string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[s.identities[[2]string{issuer, subject}]]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	user := *u
+	return &user, nil
+}
+
+func (s *fakeStore) LinkIdentity(ctx context.Context, userID int, issuer, subject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.linkIdentity(userID, issuer, subject)
+}
+
+func (s *fakeStore) linkIdentity(userID int, issuer, subject string) error {
+	key := [2]string{issuer, subject}
+	if _, exists := s.identities[key]; exists {
+		return store.ErrConflict
+	}
+	s.identities[key] = userID
+	return nil
+}
+
+func (s *fakeStore) CreateUserWithIdentity(ctx context.Context, user *models.User, issuer, subject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.identities[[2]string{issuer, subject}]; exists {
+		return store.ErrConflict
+	}
+	if err := s.createUser(user); err != nil {
+		return err
+	}
+	return s.linkIdentity(user.ID, issuer, subject)
+}
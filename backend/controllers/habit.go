@@ -1,46 +1,37 @@
 package controllers
 
 import (
+	"errors"
 	"habit-tracker/backend/models"
+	"habit-tracker/backend/store"
 	"net/http"
+	"strconv"
 	"time"
-	
+
 	"github.com/gin-gonic/gin"
 )
 
 // GET /habits
-func GetHabits(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+func (h *Handler) GetHabits(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	rows, err := db.Query("SELECT id, title, description, created_at, updated_at FROM habits WHERE user_id=$1", userID)
+	habits, err := h.store.ListHabits(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch habits"})
 		return
 	}
-	defer rows.Close()
-
-	var habits []models.Habit
-	for rows.Next() {
-		var habit models.Habit
-		habit.UserID = int(userID.(float64))
-		if err := rows.Scan(&habit.ID, &habit.Title, &habit.Description, &habit.CreatedAt, &habit.UpdatedAt); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse habit data"})
-			return
-		}
-		habits = append(habits, habit)
-	}
 
 	c.JSON(http.StatusOK, habits)
 }
 
 // POST /habits
-func CreateHabit(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+func (h *Handler) CreateHabit(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
@@ -51,56 +42,79 @@ func CreateHabit(c *gin.Context) {
 		return
 	}
 
-	habit.UserID = int(userID.(float64))
-	habit.CreatedAt = time.Now()
-	habit.UpdatedAt = time.Now()
+	if !validateHabit(c, &habit) {
+		return
+	}
 
-	query := `INSERT INTO habits(user_id, title, description, created_at, updated_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	habit.UserID = userID
+	habit.CreatedAt = h.clock.Now()
+	habit.UpdatedAt = habit.CreatedAt
 
-	err := db.QueryRow(query, habit.UserID, habit.Title, habit.Description, habit.CreatedAt, habit.UpdatedAt).Scan(&habit.ID)
-	if err != nil {
+	if err := h.store.CreateHabit(c.Request.Context(), &habit); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create habit"})
 		return
 	}
 
+	// Quit habits start with a clean streak
+	if _, err := h.recalculateStreaks(c.Request.Context(), &habit, time.Time{}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create streak"})
+		return
+	}
+
 	c.JSON(http.StatusCreated, habit)
 }
 
 // DELETE /habits/:id
-func DeleteHabit(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+func (h *Handler) DeleteHabit(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	habitID := c.Param("id") // Extract habit ID from URL path
-	query := `DELETE FROM habits WHERE id=$1 AND user_id=$2`
-	res, err := db.Exec(query, habitID, userID)
-
+	habitID, err := strconv.Atoi(c.Param("id")) // Extract habit ID from URL path
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete habit"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid habit ID"})
 		return
 	}
 
-	rowsAffected, _ := res.RowsAffected()
-	if rowsAffected == 0 {
+	err = h.store.DeleteHabit(c.Request.Context(), habitID, userID)
+	if errors.Is(err, store.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found or unauthorized"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete habit"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Habit deleted successfully"})
 }
 
 // PUT /habits/:id
-func UpdateHabit(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+func (h *Handler) UpdateHabit(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	habitID := c.Param("id")
+	habitID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid habit ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	habit, err := h.store.GetHabit(ctx, habitID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found or unauthorized"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch habit"})
+		return
+	}
 
 	var updatedHabit models.Habit
 	if err := c.ShouldBindJSON(&updatedHabit); err != nil {
@@ -108,20 +122,60 @@ func UpdateHabit(c *gin.Context) {
 		return
 	}
 
-	query := `
-		UPDATE habits
-		SET title=$1, description=$2, updated_at=$3
-		WHERE id=$4 AND user_id=$5
-		RETURNING id, title, description, created_at, updated_at
-	`
+	// An omitted kind or type keeps the stored one rather than the default
+	if updatedHabit.Kind == "" {
+		updatedHabit.Kind = habit.Kind
+	}
+	if updatedHabit.Type == "" {
+		updatedHabit.Type = habit.Type
+	}
+	if !validateHabit(c, &updatedHabit) {
+		return
+	}
+
+	// Recorded completions mean something else under another kind or type
+	if updatedHabit.Kind != habit.Kind || updatedHabit.Type != habit.Type {
+		days, err := h.store.DailyValues(ctx, habitID, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch completions"})
+			return
+		}
+		if len(days) > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cannot change the kind or type of a habit with completions"})
+			return
+		}
+	}
+
+	updatedHabit.ID = habitID
+	updatedHabit.UserID = userID
+	updatedHabit.CreatedAt = habit.CreatedAt
+	updatedHabit.UpdatedAt = h.clock.Now()
 
-	err := db.QueryRow(query, updatedHabit.Title, updatedHabit.Description, time.Now(), habitID, userID).Scan(
-		&updatedHabit.ID, &updatedHabit.Title, &updatedHabit.Description, &updatedHabit.CreatedAt, &updatedHabit.UpdatedAt,
-	)
+	err = h.store.UpdateHabit(ctx, &updatedHabit)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found or unauthorized"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update habit"})
 		return
 	}
 
+	// The schedule may have changed what counts as a streak
+	if _, err := h.recalculateStreaks(ctx, &updatedHabit, time.Time{}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update streak"})
+		return
+	}
+
 	c.JSON(http.StatusOK, updatedHabit)
-}
\ No newline at end of file
+}
+
+// validateHabit fills in defaults for a bound habit and rejects invalid ones
+func validateHabit(c *gin.Context, habit *models.Habit) bool {
+	habit.Normalize()
+	if err := habit.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
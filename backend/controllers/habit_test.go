@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/streaks"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHabitCRUD(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+
+	habit := e.createHabit(token, gin.H{"title": "Read"})
+	if habit.Kind != models.HabitBuild || habit.Type != models.HabitBoolean || habit.Schedule.Type != models.ScheduleDaily {
+		t.Errorf("habit = %+v, want a daily boolean build habit", habit)
+	}
+
+	update := gin.H{"title": "Read more", "schedule": gin.H{"type": "times_per_week", "times": 3}}
+	expect(t, e.do("PUT", habitPath(habit.ID, ""), token, update), http.StatusOK, nil)
+
+	var habits []models.Habit
+	expect(t, e.do("GET", "/habits", token, nil), http.StatusOK, &habits)
+	if len(habits) != 1 || habits[0].Title != "Read more" || habits[0].Schedule.Times != 3 {
+		t.Fatalf("habits = %+v, want the updated habit", habits)
+	}
+
+	expect(t, e.do("DELETE", habitPath(habit.ID, ""), token, nil), http.StatusOK, nil)
+	expect(t, e.do("DELETE", habitPath(habit.ID, ""), token, nil), http.StatusNotFound, nil)
+}
+
+func TestUpdateHabitKeepsKindAndType(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Smoking", "kind": "quit"})
+	water := e.createHabit(token, gin.H{"title": "Water", "type": "numeric", "target": 8})
+
+	var updated models.Habit
+	expect(t, e.do("PUT", habitPath(habit.ID, ""), token, gin.H{"title": "Cigarettes"}), http.StatusOK, &updated)
+	if updated.Kind != models.HabitQuit || !updated.CreatedAt.Equal(habit.CreatedAt) {
+		t.Errorf("habit = %+v, want it still a quit habit created at %v", updated, habit.CreatedAt)
+	}
+	expect(t, e.do("PUT", habitPath(water.ID, ""), token, gin.H{"title": "Tea", "target": 4}), http.StatusOK, &updated)
+	if updated.Type != models.HabitNumeric || updated.Target != 4 {
+		t.Errorf("habit = %+v, want it still numeric with a target of 4", updated)
+	}
+
+	// Only while nothing is recorded can it become something else
+	expect(t, e.do("PUT", habitPath(habit.ID, ""), token, gin.H{"title": "Run", "kind": "build"}), http.StatusOK, nil)
+	e.complete(token, habit.ID, day(0))
+	expect(t, e.do("PUT", habitPath(habit.ID, ""), token, gin.H{"title": "Run", "kind": "quit"}), http.StatusConflict, nil)
+	expect(t, e.do("PUT", habitPath(habit.ID, ""), token, gin.H{"title": "Run", "type": "numeric", "target": 5}), http.StatusConflict, nil)
+}
+
+func TestHabitTimestampsFollowTheClock(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	created := testNow.Add(-48 * time.Hour)
+	e.h.SetClock(streaks.FixedClock(created))
+	habit := e.createHabit(token, gin.H{"title": "Read"})
+	if !habit.CreatedAt.Equal(created) || !habit.UpdatedAt.Equal(created) {
+		t.Errorf("habit created at %v and updated at %v, want both %v", habit.CreatedAt, habit.UpdatedAt, created)
+	}
+
+	e.h.SetClock(streaks.FixedClock(testNow))
+	var updated models.Habit
+	expect(t, e.do("PUT", habitPath(habit.ID, ""), token, gin.H{"title": "Read more"}), http.StatusOK, &updated)
+	if !updated.CreatedAt.Equal(created) || !updated.UpdatedAt.Equal(testNow) {
+		t.Errorf("habit created at %v and updated at %v, want %v and %v", updated.CreatedAt, updated.UpdatedAt, created, testNow)
+	}
+}
+
+func TestCreateHabitRejectsInvalidHabits(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+
+	tests := map[string]gin.H{
+		"no title":         {"description": "untitled"},
+		"no weekdays":      {"title": "Gym", "schedule": gin.H{"type": "weekdays"}},
+		"zero times":       {"title": "Gym", "schedule": gin.H{"type": "times_per_week"}},
+		"unknown schedule": {"title": "Gym", "schedule": gin.H{"type": "sometimes"}},
+		"no target":        {"title": "Water", "type": "numeric"},
+		"unknown type":     {"title": "Water", "type": "liters"},
+		"numeric quit":     {"title": "Snacks", "kind": "quit", "type": "numeric", "target": 2},
+		"scheduled quit":   {"title": "Smoking", "kind": "quit", "schedule": gin.H{"type": "times_per_week", "times": 3}},
+		"unknown kind":     {"title": "Read", "kind": "maybe"},
+	}
+	for name, habit := range tests {
+		t.Run(name, func(t *testing.T) {
+			expect(t, e.do("POST", "/habits", token, habit), http.StatusBadRequest, nil)
+		})
+	}
+	if len(e.store.habits) != 0 {
+		t.Errorf("%d habits were created, want none", len(e.store.habits))
+	}
+}
+
+func TestHabitsOfOtherUsersAreHidden(t *testing.T) {
+	e := newTestEnv(t)
+	_, ann := e.signUp("ann@example.com")
+	_, bob := e.signUp("bob@example.com")
+	habit := e.createHabit(ann, gin.H{"title": "Read"})
+
+	var habits []models.Habit
+	expect(t, e.do("GET", "/habits", bob, nil), http.StatusOK, &habits)
+	if len(habits) != 0 {
+		t.Errorf("bob sees %d habits, want none", len(habits))
+	}
+	expect(t, e.do("PUT", habitPath(habit.ID, ""), bob, gin.H{"title": "Mine now"}), http.StatusNotFound, nil)
+	expect(t, e.do("POST", habitPath(habit.ID, ""), bob, nil), http.StatusNotFound, nil)
+	expect(t, e.do("DELETE", habitPath(habit.ID, ""), bob, nil), http.StatusNotFound, nil)
+}
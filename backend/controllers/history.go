@@ -1,18 +1,18 @@
 package controllers
 
 import (
-	"fmt"
+	"errors"
+	"habit-tracker/backend/store"
 	"net/http"
 	"strconv"
-	"time"
-	
+
 	"github.com/gin-gonic/gin"
 )
 
 // GET /habits/<id>/history
-func GetHabitHistory(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+func (h *Handler) GetHabitHistory(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
@@ -23,32 +23,45 @@ func GetHabitHistory(c *gin.Context) {
 		return
 	}
 
-	query := `
-		SELECT date_completed
-		FROM habit_completions
-		WHERE habit_id = $1 AND user_id = $2
-		ORDER BY date_completed ASC
-	`
+	habit, err := h.store.GetHabit(c.Request.Context(), habitID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch habit"})
+		return
+	}
 
-	rows, err := db.Query(query, habitID, userID)
+	values, err := h.store.DailyValues(c.Request.Context(), habitID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch history"})
 		return
 	}
-	defer rows.Close()
 
+	// history keeps the completed dates, days carries every recorded day with its total
 	var history []string
-	for rows.Next() {
-		var date time.Time
-		if err := rows.Scan(&date); err != nil {
-			fmt.Println("scan error: ", err)
-			continue
+	days := []gin.H{}
+	for _, dv := range values {
+		date := dv.Date.Format("2006-01-02")
+		completed := habit.MeetsTarget(dv.Value)
+		if completed {
+			history = append(history, date)
 		}
-		history = append(history, date.Format("2006-01-02"))
+		days = append(days, gin.H{
+			"date":      date,
+			"value":     dv.Value,
+			"completed": completed,
+			"note":      dv.Note,
+		})
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"habit_id": habitID,
+		"type":     habit.Type,
+		"target":   habit.Target,
+		"unit":     habit.Unit,
 		"history":  history,
+		"days":     days,
 	})
-}
\ No newline at end of file
+}
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHabitHistoryKeepsDaysShortOfTarget(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Run", "type": "numeric", "target": 5, "unit": "km"})
+	for offset, km := range map[int]float64{-2: 6, -1: 2, 0: 5} {
+		expect(t, e.do("POST", habitPath(habit.ID, "?date="+day(offset)), token, gin.H{"value": km}), http.StatusOK, nil)
+	}
+
+	var history struct {
+		Unit    string   `json:"unit"`
+		History []string `json:"history"`
+		Days    []struct {
+			Date      string  `json:"date"`
+			Value     float64 `json:"value"`
+			Completed bool    `json:"completed"`
+		} `json:"days"`
+	}
+	expect(t, e.do("GET", habitPath(habit.ID, "/history"), token, nil), http.StatusOK, &history)
+
+	if !slices.Equal(history.History, []string{day(-2), day(0)}) {
+		t.Errorf("history = %v, want the two days meeting 5 km", history.History)
+	}
+	if len(history.Days) != 3 || history.Days[1].Value != 2 || history.Days[1].Completed {
+		t.Errorf("days = %+v, want all three, yesterday at 2 km and not completed", history.Days)
+	}
+	if history.Unit != "km" {
+		t.Errorf("unit = %q, want km", history.Unit)
+	}
+
+	expect(t, e.do("GET", habitPath(habit.ID+1, "/history"), token, nil), http.StatusNotFound, nil)
+}
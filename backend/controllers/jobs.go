@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// streakRecomputeLease is how long a replica may spend on one user before another
+// assumes it died and takes the work over
+const streakRecomputeLease = 10 * time.Minute
+
+// RecomputeStreaks refreshes the persisted streaks of every user whose local day has
+// rolled over since their last recompute, so streaks of habits nobody completes
+// still decay. Each user and day is leased in the database first, which keeps
+// several replicas from doing the same work, and only marked done once every habit
+// was saved, so a crash or failure mid-run leaves it to be retried when the lease
+// runs out. It returns how many users were processed.
+func (h *Handler) RecomputeStreaks(ctx context.Context) (int, error) {
+	users, err := h.store.ListUsers(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for _, user := range users {
+		if err := ctx.Err(); err != nil {
+			return processed, err
+		}
+
+		day := h.today(user.Location())
+		now := h.clock.Now()
+		claimed, err := h.store.ClaimStreakRecompute(ctx, user.ID, day, now, now.Add(streakRecomputeLease))
+		if err != nil {
+			return processed, err
+		}
+		if !claimed {
+			continue
+		}
+
+		// Only the days since the last run have new strength scores
+		if err := h.recalculateUserStreaks(ctx, user.ID, day); err != nil {
+			if ctx.Err() != nil {
+				return processed, ctx.Err()
+			}
+			log.Printf("⚠️  Failed to recompute streaks of user %d, retrying later: %v", user.ID, err)
+			continue
+		}
+		if err := h.store.FinishStreakRecompute(ctx, user.ID, day); err != nil {
+			return processed, err
+		}
+		processed++
+	}
+	return processed, nil
+}
+
+// recalculateUserStreaks recomputes the streaks of all the user's habits, with since
+// as for recalculateStreaks. A habit that fails is logged and the others still
+// brought up to date, but the failure is reported.
+func (h *Handler) recalculateUserStreaks(ctx context.Context, userID int, since time.Time) error {
+	habits, err := h.store.ListHabits(ctx, userID)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for i := range habits {
+		if _, err := h.recalculateStreaks(ctx, &habits[i], since); err != nil {
+			log.Printf("⚠️  Failed to recompute streak of habit %d: %v", habits[i].ID, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d habits failed", failed, len(habits))
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"context"
+	"habit-tracker/backend/streaks"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRecomputeStreaksDecaysStaleStreaks(t *testing.T) {
+	e := newTestEnv(t)
+	user, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Read"})
+	e.complete(token, habit.ID, day(-1), day(0))
+
+	// Two days on, nobody has completed the habit since
+	e.h.SetClock(streaks.FixedClock(testNow.AddDate(0, 0, 2)))
+	ctx := context.Background()
+	if n, err := e.h.RecomputeStreaks(ctx); err != nil || n != 1 {
+		t.Fatalf("RecomputeStreaks = %d, %v; want 1 user", n, err)
+	}
+	if streak := e.store.streaks[habit.ID]; streak.CurrentStreak != 0 || streak.LongestStreak != 2 {
+		t.Errorf("streak = %+v, want current 0 and longest 2", streak)
+	}
+
+	// The day is finished, so running again on it does nothing
+	key := recomputeKey{user.ID, day(2)}
+	if lease, finished := e.store.recomputes[key]; !finished || lease != nil {
+		t.Errorf("day %s of user %d was not marked finished", day(2), user.ID)
+	}
+	if n, err := e.h.RecomputeStreaks(ctx); err != nil || n != 0 {
+		t.Errorf("second RecomputeStreaks = %d, %v; want 0 users", n, err)
+	}
+}
+
+func TestRecomputeStreaksTakesOverExpiredLeases(t *testing.T) {
+	e := newTestEnv(t)
+	user, _ := e.signUp("ann@example.com")
+	ctx := context.Background()
+	key := recomputeKey{user.ID, day(0)}
+
+	// Another replica is still within its lease
+	lease := testNow.Add(time.Minute)
+	e.store.recomputes[key] = &lease
+	if n, err := e.h.RecomputeStreaks(ctx); err != nil || n != 0 {
+		t.Fatalf("RecomputeStreaks = %d, %v; want 0 users under a live lease", n, err)
+	}
+
+	// It died before finishing
+	lease = testNow.Add(-time.Minute)
+	if n, err := e.h.RecomputeStreaks(ctx); err != nil || n != 1 {
+		t.Fatalf("RecomputeStreaks = %d, %v; want the expired lease taken over", n, err)
+	}
+	if e.store.recomputes[key] != nil {
+		t.Errorf("day %s of user %d was not marked finished", day(0), user.ID)
+	}
+}
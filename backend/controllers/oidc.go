@@ -0,0 +1,322 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/oidc"
+	"habit-tracker/backend/store"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// oidcFlowCookie carries the state, nonce and PKCE verifier through the provider
+	oidcFlowCookie = "oidc_flow"
+	oidcFlowTTL    = 10 * time.Minute
+	oidcFlowType   = "oidc_flow"
+	// oidcCodeTTL is how long the frontend has to redeem the code the callback hands it
+	oidcCodeTTL = time.Minute
+	// oidcFrontendPath is the frontend page the callback sends the browser back to
+	oidcFrontendPath = "/auth/callback"
+)
+
+// oidcFlowClaims are the contents of the flow cookie
+type oidcFlowClaims struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Reauth   bool   `json:"reauth,omitempty"`
+	Type     string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// GET /auth/oidc/login?reauth=1
+func (h *Handler) OIDCLogin(c *gin.Context) {
+	flow, err := oidc.NewFlow()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sign-in"})
+		return
+	}
+	flow.Reauth = c.Query("reauth") == "1"
+
+	authURL, err := h.opts.OIDC.AuthCodeURL(c.Request.Context(), flow)
+	if err != nil {
+		log.Printf("❌ OIDC discovery failed: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+		return
+	}
+
+	// The flow secrets travel in a signed cookie, so no server-side state is needed
+	cookie, err := h.signInternal(&oidcFlowClaims{
+		State:    flow.State,
+		Nonce:    flow.Nonce,
+		Verifier: flow.Verifier,
+		Reauth:   flow.Reauth,
+		Type:     oidcFlowType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(oidcFlowTTL)),
+		},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sign-in"})
+		return
+	}
+	h.setFlowCookie(c, cookie, int(oidcFlowTTL.Seconds()))
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// GET /auth/oidc/callback
+//
+// Tokens never appear in the redirect: the browser is sent back to the frontend with
+// a one-time code in the URL fragment, which stays out of server logs and Referer
+// headers, and the frontend redeems it at POST /auth/oidc/token or POST /me/reauth/oidc.
+func (h *Handler) OIDCCallback(c *gin.Context) {
+	if errCode := c.Query("error"); errCode != "" {
+		h.oidcRedirect(c, url.Values{"error": {"Sign-in was refused by the identity provider: " + errCode}})
+		return
+	}
+
+	cookie, _ := c.Cookie(oidcFlowCookie)
+	h.setFlowCookie(c, "", -1)
+	flow, ok := h.parseFlowCookie(cookie)
+	if !ok || subtle.ConstantTimeCompare([]byte(flow.State), []byte(c.Query("state"))) != 1 {
+		h.oidcRedirect(c, url.Values{"error": {"Sign-in expired or was started elsewhere, please try again"}})
+		return
+	}
+	code := c.Query("code")
+	if code == "" {
+		h.oidcRedirect(c, url.Values{"error": {"Missing authorization code"}})
+		return
+	}
+
+	ctx := c.Request.Context()
+	claims, err := h.opts.OIDC.Exchange(ctx, code, flow)
+	if err != nil {
+		log.Printf("❌ OIDC sign-in failed: %v", err)
+		h.oidcRedirect(c, url.Values{"error": {"Single sign-on failed"}})
+		return
+	}
+
+	purpose := models.TokenOIDCLogin
+	var user *models.User
+	if flow.Reauth {
+		// Re-authenticating only confirms an identity already linked, it never links or creates one
+		purpose = models.TokenOIDCReauth
+		user, err = h.store.GetUserByIdentity(ctx, claims.Issuer, claims.Subject)
+		if errors.Is(err, store.ErrNotFound) {
+			h.oidcRedirect(c, url.Values{"error": {"This identity is not linked to an account"}})
+			return
+		}
+		if err != nil {
+			h.oidcRedirect(c, url.Values{"error": {"Failed to fetch user"}})
+			return
+		}
+	} else {
+		var message string
+		if user, message = h.oidcUser(ctx, claims); user == nil {
+			h.oidcRedirect(c, url.Values{"error": {message}})
+			return
+		}
+	}
+
+	secret, err := h.createUserToken(ctx, user.ID, purpose, oidcCodeTTL)
+	if err != nil {
+		h.oidcRedirect(c, url.Values{"error": {"Failed to finish sign-in"}})
+		return
+	}
+	values := url.Values{"code": {secret}}
+	if flow.Reauth {
+		values.Set("reauth", "1")
+	}
+	h.oidcRedirect(c, values)
+}
+
+// oidcRedirect sends the browser back to the frontend with values in the URL fragment
+func (h *Handler) oidcRedirect(c *gin.Context, values url.Values) {
+	c.Redirect(http.StatusFound, strings.TrimRight(h.opts.AppURL, "/")+oidcFrontendPath+"#"+values.Encode())
+}
+
+// POST /auth/oidc/token
+func (h *Handler) OIDCToken(c *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	token, err := h.store.ConsumeUserToken(ctx, models.TokenOIDCLogin, store.HashToken(input.Code))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired code"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check code"})
+		return
+	}
+
+	user, err := h.store.GetUserByID(ctx, token.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired code"})
+		return
+	}
+	h.finishLogin(c, user)
+}
+
+// POST /me/reauth/oidc
+func (h *Handler) ReauthOIDC(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := h.store.ConsumeUserToken(c.Request.Context(), models.TokenOIDCReauth, store.HashToken(input.Code))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired code"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check code"})
+		return
+	}
+	if token.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Signed in to the identity provider as a different account"})
+		return
+	}
+
+	reauthToken, err := h.signInternal(&reauthClaims{
+		UserID: userID,
+		Type:   reauthTokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(reauthTokenTTL)),
+		},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reauth_token": reauthToken,
+		"expires_in":   int(reauthTokenTTL.Seconds()),
+	})
+}
+
+func (h *Handler) setFlowCookie(c *gin.Context, value string, maxAge int) {
+	secure := strings.HasPrefix(h.opts.OIDC.RedirectURL(), "https://")
+	// Lax still sends the cookie on the provider's top-level redirect back to us
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookie, value, maxAge, "/auth/oidc", "", secure, true)
+}
+
+func (h *Handler) parseFlowCookie(value string) (oidc.Flow, bool) {
+	if value == "" {
+		return oidc.Flow{}, false
+	}
+	var claims oidcFlowClaims
+	if err := h.parseInternal(value, &claims); err != nil || claims.Type != oidcFlowType {
+		return oidc.Flow{}, false
+	}
+	return oidc.Flow{State: claims.State, Nonce: claims.Nonce, Verifier: claims.Verifier, Reauth: claims.Reauth}, claims.State != ""
+}
+
+// oidcUser finds the user a provider account signs in as, linking it to an existing
+// account with the same verified email or creating a new one. It returns nil and a
+// message for the user if the account cannot be used.
+func (h *Handler) oidcUser(ctx context.Context, claims *oidc.Claims) (*models.User, string) {
+	user, err := h.store.GetUserByIdentity(ctx, claims.Issuer, claims.Subject)
+	if err == nil {
+		return user, ""
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, "Failed to fetch user"
+	}
+
+	if claims.Email == "" {
+		return nil, "The identity provider did not share an email address"
+	}
+
+	user, err = h.store.GetUserByEmail(ctx, claims.Email)
+	switch {
+	case err == nil:
+		// Only link when both sides have proven the address, otherwise whoever
+		// registered it first could take over the other's account
+		if !claims.EmailVerified || !user.EmailVerified {
+			return nil, "An account with this email already exists and could not be linked"
+		}
+		if err := h.store.LinkIdentity(ctx, user.ID, claims.Issuer, claims.Subject); err != nil {
+			return nil, "Failed to link account"
+		}
+		log.Printf("✅ Linked single sign-on identity to user %d", user.ID)
+		return user, ""
+	case !errors.Is(err, store.ErrNotFound):
+		return nil, "Failed to fetch user"
+	}
+
+	// Accounts created by single sign-on get a random password; a reset, or a change
+	// after re-authenticating with the provider, sets a real one
+	password, err := randomToken(32)
+	if err != nil {
+		return nil, "Failed to create user"
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, "Failed to hash password"
+	}
+	user = &models.User{
+		Username:      oidcUsername(claims),
+		Email:         claims.Email,
+		Password:      string(hashedPassword),
+		TimeZone:      models.DefaultTimeZone,
+		EmailVerified: claims.EmailVerified,
+		CreatedAt:     time.Now(),
+	}
+	err = h.store.CreateUserWithIdentity(ctx, user, claims.Issuer, claims.Subject)
+	if errors.Is(err, store.ErrConflict) {
+		return nil, "An account with this email already exists and could not be linked"
+	}
+	if err != nil {
+		return nil, "Failed to create user"
+	}
+	log.Printf("✅ Created user %d from single sign-on", user.ID)
+
+	if !user.EmailVerified {
+		if err := h.sendVerificationEmail(ctx, user); err != nil {
+			log.Printf("❌ Failed to send verification email to user %d: %v", user.ID, err)
+		}
+	}
+	return user, ""
+}
+
+// oidcUsername picks a display name from the provider's profile claims
+func oidcUsername(claims *oidc.Claims) string {
+	switch {
+	case claims.PreferredUsername != "":
+		return claims.PreferredUsername
+	case claims.Name != "":
+		return claims.Name
+	}
+	name, _, _ := strings.Cut(claims.Email, "@")
+	return name
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"context"
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/oidc"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The redirects to and from the identity provider are covered by the oidc package;
+// these start from the claims of a verified ID token, or from the one-time code the
+// callback hands the frontend.
+
+// signInAs runs oidcUser for claims and returns the user ID it signs in as, or 0
+// with the message explaining why not
+func (e *testEnv) signInAs(claims oidc.Claims) (int, string) {
+	e.t.Helper()
+	user, message := e.h.oidcUser(context.Background(), &claims)
+	if user == nil {
+		return 0, message
+	}
+	return user.ID, ""
+}
+
+func TestOIDCUserCreatesAndLinksAccounts(t *testing.T) {
+	e := newTestEnv(t)
+	const issuer = "https://id.example.com"
+
+	// A new address gets a new account, which the same identity signs in as again
+	created, message := e.signInAs(oidc.Claims{Issuer: issuer, Subject: "1", Email: "new@example.com", EmailVerified: true, PreferredUsername: "newbie"})
+	if created == 0 || e.store.users[created].Username != "newbie" || !e.store.users[created].EmailVerified {
+		t.Fatalf("created user %d (%q), want a verified newbie", created, message)
+	}
+	if again, _ := e.signInAs(oidc.Claims{Issuer: issuer, Subject: "1"}); again != created {
+		t.Errorf("the identity signs in as user %d, want %d", again, created)
+	}
+
+	// An existing account is only linked when both sides have verified the address
+	ann, _ := e.signUp("ann@example.com")
+	if linked, _ := e.signInAs(oidc.Claims{Issuer: issuer, Subject: "2", Email: "ann@example.com", EmailVerified: true}); linked != 0 {
+		t.Errorf("an unverified account was linked as user %d", linked)
+	}
+	e.store.users[ann.ID].EmailVerified = true
+	if linked, _ := e.signInAs(oidc.Claims{Issuer: issuer, Subject: "2", Email: "ann@example.com"}); linked != 0 {
+		t.Errorf("an address the provider did not verify was linked as user %d", linked)
+	}
+	if linked, _ := e.signInAs(oidc.Claims{Issuer: issuer, Subject: "2", Email: "ann@example.com", EmailVerified: true}); linked != ann.ID {
+		t.Errorf("the identity signs in as user %d, want %d", linked, ann.ID)
+	}
+
+	if created, _ := e.signInAs(oidc.Claims{Issuer: issuer, Subject: "3"}); created != 0 {
+		t.Errorf("an identity without email signed in as user %d", created)
+	}
+}
+
+func TestOIDCTokenIsSingleUse(t *testing.T) {
+	e := newTestEnv(t)
+	user, _ := e.signUp("ann@example.com")
+	code, err := e.h.createUserToken(context.Background(), user.ID, models.TokenOIDCLogin, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var login loginResponse
+	expect(t, e.do("POST", "/auth/oidc/token", "", gin.H{"code": code}), http.StatusOK, &login)
+	expect(t, e.do("GET", "/me", login.Token, nil), http.StatusOK, nil)
+	expect(t, e.do("POST", "/auth/oidc/token", "", gin.H{"code": code}), http.StatusUnauthorized, nil)
+}
+
+func TestReauthOIDCStandsInForPassword(t *testing.T) {
+	e := newTestEnv(t)
+	user, token := e.signUp("ann@example.com")
+	bob, _ := e.signUp("bob@example.com")
+
+	// A code for someone else's account, or for logging in, is no re-authentication
+	bobs, err := e.h.createUserToken(context.Background(), bob.ID, models.TokenOIDCReauth, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, e.do("POST", "/me/reauth/oidc", token, gin.H{"code": bobs}), http.StatusForbidden, nil)
+	login, err := e.h.createUserToken(context.Background(), user.ID, models.TokenOIDCLogin, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, e.do("POST", "/me/reauth/oidc", token, gin.H{"code": login}), http.StatusForbidden, nil)
+
+	code, err := e.h.createUserToken(context.Background(), user.ID, models.TokenOIDCReauth, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reauth struct {
+		ReauthToken string `json:"reauth_token"`
+	}
+	expect(t, e.do("POST", "/me/reauth/oidc", token, gin.H{"code": code}), http.StatusOK, &reauth)
+
+	expect(t, e.do("DELETE", "/me", token, gin.H{"reauth_token": "forged"}), http.StatusForbidden, nil)
+	expect(t, e.do("DELETE", "/me", token, gin.H{"reauth_token": reauth.ReauthToken}), http.StatusOK, nil)
+}
@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/store"
+	"habit-tracker/backend/streaks"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// weekdayCounts is how a habit went on one day of the week. Days counts every
+// scheduled day that is over, Missed those the schedule needed but did not get.
+// For quit habits Done counts clean days and Missed the slips.
+type weekdayCounts struct {
+	Weekday string  `json:"weekday"`
+	Done    int     `json:"done"`
+	Missed  int     `json:"missed"`
+	Days    int     `json:"days"`
+	Rate    float64 `json:"rate"`
+}
+
+// habitPatterns are the weekday and hour-of-day patterns of one habit
+type habitPatterns struct {
+	weekdays [7]weekdayCounts // Monday first
+	hours    [24]int
+}
+
+// GET /habits/:id/patterns?from=&to=
+func (h *Handler) GetHabitPatterns(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	habitID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid habit ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	habit, err := h.store.GetHabit(ctx, habitID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch habit"})
+		return
+	}
+
+	loc, err := h.userLocation(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	from, to, ok := h.calendarRange(c, loc)
+	if !ok {
+		return
+	}
+
+	all, err := h.computePatterns(ctx, userID, habitID, []models.Habit{*habit}, loc, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute patterns"})
+		return
+	}
+
+	response := patternsResponse(&all[0], from, to)
+	response["habit_id"] = habit.ID
+	response["title"] = habit.Title
+	response["kind"] = habit.Kind
+	c.JSON(http.StatusOK, response)
+}
+
+// GET /habits/patterns?from=&to= - the patterns of all habits together and of each one
+func (h *Handler) GetPatterns(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	loc, err := h.userLocation(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	from, to, ok := h.calendarRange(c, loc)
+	if !ok {
+		return
+	}
+
+	habits, err := h.store.ListHabits(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch habits"})
+		return
+	}
+
+	all, err := h.computePatterns(ctx, userID, 0, habits, loc, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute patterns"})
+		return
+	}
+
+	var total habitPatterns
+	initWeekdays(&total)
+	perHabit := []gin.H{}
+	for i := range habits {
+		for d := range total.weekdays {
+			total.weekdays[d].Done += all[i].weekdays[d].Done
+			total.weekdays[d].Missed += all[i].weekdays[d].Missed
+			total.weekdays[d].Days += all[i].weekdays[d].Days
+		}
+		// Slips say when a quit habit goes wrong, not when to be reminded
+		if habits[i].Kind != models.HabitQuit {
+			for hour, n := range all[i].hours {
+				total.hours[hour] += n
+			}
+		}
+
+		response := patternsResponse(&all[i], from, to)
+		delete(response, "from")
+		delete(response, "to")
+		response["habit_id"] = habits[i].ID
+		response["title"] = habits[i].Title
+		response["kind"] = habits[i].Kind
+		perHabit = append(perHabit, response)
+	}
+
+	response := patternsResponse(&total, from, to)
+	response["habits"] = perHabit
+	c.JSON(http.StatusOK, response)
+}
+
+// computePatterns returns the patterns of each of habits from from to to, in order.
+// habitID restricts the queries to one habit, or is 0 when habits are all the user's habits.
+func (h *Handler) computePatterns(ctx context.Context, userID, habitID int, habits []models.Habit, loc *time.Location, from, to time.Time) ([]habitPatterns, error) {
+	checkIns, err := h.store.CheckIns(ctx, userID, habitID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	hours := make(map[int]*[24]int)
+	for _, ci := range checkIns {
+		// A completion backdated on a later day says nothing about the usual hour
+		at := ci.CompletedAt.In(loc)
+		if !streaks.Day(at).Equal(ci.Date) {
+			continue
+		}
+		if hours[ci.HabitID] == nil {
+			hours[ci.HabitID] = new([24]int)
+		}
+		hours[ci.HabitID][at.Hour()]++
+	}
+
+	result := make([]habitPatterns, len(habits))
+	for i := range habits {
+		hp := &result[i]
+		initWeekdays(hp)
+		if counted := hours[habits[i].ID]; counted != nil {
+			hp.hours = *counted
+		}
+
+		days, err := h.habitCalendar(ctx, &habits[i], loc, from, to)
+		if err != nil {
+			return nil, err
+		}
+		for _, day := range days {
+			date, _ := time.Parse("2006-01-02", day.Date)
+			wc := &hp.weekdays[(int(date.Weekday())+6)%7]
+			switch day.Status {
+			case streaks.StatusDone:
+				wc.Done++
+			case streaks.StatusMissed:
+				wc.Missed++
+			case streaks.StatusSkipped:
+			default:
+				continue
+			}
+			wc.Days++
+		}
+	}
+	return result, nil
+}
+
+// initWeekdays names the weekdays of hp, Monday first
+func initWeekdays(hp *habitPatterns) {
+	for i := range hp.weekdays {
+		hp.weekdays[i].Weekday = time.Weekday((i + 1) % 7).String()
+	}
+}
+
+// patternsResponse writes the weekday rates, the hour histogram and the hour with
+// the most check-ins, or nil if there were none
+func patternsResponse(hp *habitPatterns, from, to time.Time) gin.H {
+	weekdays := hp.weekdays
+	for i := range weekdays {
+		if weekdays[i].Days > 0 {
+			weekdays[i].Rate = math.Round(float64(weekdays[i].Done)/float64(weekdays[i].Days)*10000) / 100
+		}
+	}
+
+	var peak *int
+	for hour, n := range hp.hours {
+		if n > 0 && (peak == nil || n > hp.hours[*peak]) {
+			peak = &hour
+		}
+	}
+
+	return gin.H{
+		"from":      from.Format("2006-01-02"),
+		"to":        to.Format("2006-01-02"),
+		"weekdays":  weekdays,
+		"hours":     hp.hours,
+		"peak_hour": peak,
+	}
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"habit-tracker/backend/streaks"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// weekdayIndex is the position of the weekday of date in a Monday-first week
+func weekdayIndex(date string) int {
+	d, _ := time.Parse("2006-01-02", date)
+	return (int(d.Weekday()) + 6) % 7
+}
+
+func TestHabitPatterns(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Read"})
+	e.backdate(habit.ID, testNow.AddDate(0, 0, -13))
+
+	// Two check-ins at 07:00 on the days they count for; the one logged today for
+	// yesterday says nothing about when the habit gets done
+	for _, offset := range []int{-13, -6} {
+		d, _ := time.Parse("2006-01-02", day(offset))
+		e.h.SetClock(streaks.FixedClock(d.Add(7 * time.Hour)))
+		e.complete(token, habit.ID, day(offset))
+	}
+	e.h.SetClock(streaks.FixedClock(testNow))
+	e.complete(token, habit.ID, day(-1))
+
+	var patterns struct {
+		Weekdays []weekdayCounts `json:"weekdays"`
+		Hours    []int           `json:"hours"`
+		PeakHour *int            `json:"peak_hour"`
+	}
+	path := habitPath(habit.ID, "/patterns?from="+day(-13)+"&to="+day(0))
+	expect(t, e.do("GET", path, token, nil), http.StatusOK, &patterns)
+
+	if patterns.PeakHour == nil || *patterns.PeakHour != 7 || patterns.Hours[7] != 2 {
+		t.Errorf("hours = %v, peak %v; want only the 2 check-ins at 7", patterns.Hours, patterns.PeakHour)
+	}
+	// Both weeks were done on the weekday of the check-ins; on the weekday of
+	// yesterday only yesterday was, the week before was missed
+	if counts := patterns.Weekdays[weekdayIndex(day(-6))]; counts.Done != 2 || counts.Days != 2 || counts.Rate != 100 {
+		t.Errorf("%s = %+v, want 2 of 2", counts.Weekday, counts)
+	}
+	if counts := patterns.Weekdays[weekdayIndex(day(-1))]; counts.Done != 1 || counts.Missed != 1 || counts.Rate != 50 {
+		t.Errorf("%s = %+v, want 1 of 2", counts.Weekday, counts)
+	}
+}
+
+func TestPatternsWithoutCheckIns(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Read"})
+	e.backdate(habit.ID, testNow.AddDate(0, 0, -7))
+
+	// Backfilled days have no check-in time to learn from
+	body := gin.H{"habits": []gin.H{{"habit_id": habit.ID, "from": day(-7), "to": day(-1)}}}
+	expect(t, e.do("POST", "/habits/backfill", token, body), http.StatusOK, nil)
+
+	var patterns struct {
+		Habits []struct {
+			HabitID  int  `json:"habit_id"`
+			PeakHour *int `json:"peak_hour"`
+		} `json:"habits"`
+	}
+	expect(t, e.do("GET", "/habits/patterns", token, nil), http.StatusOK, &patterns)
+
+	if len(patterns.Habits) != 1 || patterns.Habits[0].HabitID != habit.ID || patterns.Habits[0].PeakHour != nil {
+		t.Errorf("patterns = %+v, want habit %d without a peak hour", patterns.Habits, habit.ID)
+	}
+}
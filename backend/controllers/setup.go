@@ -1,18 +1,81 @@
 package controllers
 
 import (
-    "database/sql"
+	"habit-tracker/backend/authtoken"
+	"habit-tracker/backend/mailer"
+	"habit-tracker/backend/oidc"
+	"habit-tracker/backend/ratelimit"
+	"habit-tracker/backend/store"
+	"habit-tracker/backend/streaks"
+	"time"
+
+	"github.com/gin-gonic/gin"
 )
 
-var db *sql.DB
-var jwtSecret []byte
+// Handler holds the dependencies shared by all controllers
+type Handler struct {
+	store     store.Store
+	jwtSecret []byte
+	clock     streaks.Clock
+	opts      Options
+}
+
+// Options configures the optional behaviour of a Handler
+type Options struct {
+	// Mailer sends verification and password reset emails; nil logs them
+	Mailer mailer.Mailer
+	// AppURL is the frontend address that emailed links point to
+	AppURL string
+	// RequireEmailVerification refuses logins from users who have not verified their email
+	RequireEmailVerification bool
+	// Limiter keeps the per-account login and registration limits; nil keeps them in memory
+	Limiter ratelimit.Store
+	// OIDC is the single sign-on provider; nil disables single sign-on
+	OIDC *oidc.Provider
+	// Keys sign access tokens; nil signs them with the JWT secret
+	Keys *authtoken.Keys
+}
+
+// NewHandler creates a Handler backed by the given store and JWT secret
+func NewHandler(s store.Store, jwtSecret []byte, opts Options) *Handler {
+	if opts.Mailer == nil {
+		opts.Mailer = mailer.NewLog("", "Habit Tracker <no-reply@localhost>")
+	}
+	if opts.Keys == nil {
+		opts.Keys = authtoken.NewHMAC(jwtSecret)
+	}
+	if opts.Limiter == nil {
+		opts.Limiter = ratelimit.NewMemoryStore()
+	}
+	return &Handler{store: s, jwtSecret: jwtSecret, clock: streaks.SystemClock, opts: opts}
+}
 
-// SetDB sets the database connection for all controllers
-func SetDB(database *sql.DB) {
-    db = database
+// SetClock replaces the clock used to decide what "today" is
+func (h *Handler) SetClock(clock streaks.Clock) {
+	h.clock = clock
 }
 
-// SetJWTSecret sets the JWT secret for authentication
-func SetJWTSecret(secret []byte) {
-    jwtSecret = secret
-}
\ No newline at end of file
+// today returns the current calendar day in loc
+func (h *Handler) today(loc *time.Location) time.Time {
+	return streaks.Today(h.clock, loc)
+}
+
+// currentUserID returns the authenticated user's ID set by AuthMiddleware
+func currentUserID(c *gin.Context) (int, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return 0, false
+	}
+	id, ok := userID.(int)
+	return id, ok
+}
+
+// currentSessionID returns the session the access token was issued for, set by AuthMiddleware
+func currentSessionID(c *gin.Context) (int, bool) {
+	sessionID, exists := c.Get("session_id")
+	if !exists {
+		return 0, false
+	}
+	id, ok := sessionID.(int)
+	return id, ok
+}
@@ -0,0 +1,248 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"habit-tracker/backend/mailer"
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/streaks"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testNow is "now" for the handlers under test, in UTC like the users signUp creates
+var testNow = time.Now().UTC()
+
+// testPassword is the password of every user signUp creates
+const testPassword = "correct horse battery"
+
+var testSecret = []byte("test-secret")
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testEnv is a Handler on a fakeStore, routed like main.go
+type testEnv struct {
+	t      *testing.T
+	store  *fakeStore
+	mail   *fakeMailer
+	h      *Handler
+	router *gin.Engine
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	e := &testEnv{t: t, store: newFakeStore(), mail: &fakeMailer{}}
+	e.h = NewHandler(e.store, testSecret, Options{Mailer: e.mail, AppURL: "http://app.test"})
+	e.h.SetClock(streaks.FixedClock(testNow))
+
+	r := gin.New()
+	r.GET("/.well-known/jwks.json", e.h.JWKS)
+	r.POST("/users", e.h.RegisterUser)
+	r.POST("/login", e.h.LoginUser)
+	r.POST("/login/mfa", e.h.VerifyMFA)
+	r.POST("/auth/oidc/token", e.h.OIDCToken)
+	r.POST("/token/refresh", e.h.RefreshToken)
+	r.POST("/verify-email", e.h.VerifyEmail)
+	r.POST("/password/forgot", e.h.ForgotPassword)
+	r.POST("/password/reset", e.h.ResetPassword)
+	r.POST("/me/email/confirm", e.h.ConfirmEmailChange)
+
+	api := r.Group("/", e.auth)
+	api.POST("/logout", e.h.Logout)
+	api.POST("/logout-all", e.h.LogoutAll)
+	api.POST("/verify-email/resend", e.h.ResendVerification)
+	api.GET("/habits", e.h.GetHabits)
+	api.POST("/habits", e.h.CreateHabit)
+	api.PUT("/habits/:id", e.h.UpdateHabit)
+	api.DELETE("/habits/:id", e.h.DeleteHabit)
+	api.POST("/habits/:id", e.h.CompleteHabit)
+	api.PUT("/habits/:id/completions/:date", e.h.UpdateCompletion)
+	api.DELETE("/habits/:id/completions/:date", e.h.DeleteCompletion)
+	api.POST("/habits/backfill", e.h.BackfillCompletions)
+	api.GET("/habits/completed", e.h.GetCompletedHabits)
+	api.GET("/habits/streak", e.h.GetHabitsStreaks)
+	api.GET("/habits/:id/history", e.h.GetHabitHistory)
+	api.GET("/habits/:id/analytics", e.h.GetHabitAnalytics)
+	api.GET("/habits/:id/strength", e.h.GetHabitStrength)
+	api.GET("/habits/:id/calendar", e.h.GetHabitCalendar)
+	api.GET("/habits/calendar", e.h.GetCalendar)
+	api.GET("/habits/:id/stats", e.h.GetHabitStats)
+	api.GET("/habits/stats", e.h.GetStats)
+	api.GET("/habits/:id/patterns", e.h.GetHabitPatterns)
+	api.GET("/habits/patterns", e.h.GetPatterns)
+	api.GET("/habits/correlations", e.h.GetCorrelations)
+	api.GET("/habits/summary", e.h.GetHabitSummary)
+	api.GET("/me", e.h.GetProfile)
+	api.PATCH("/me", e.h.UpdateProfile)
+	api.DELETE("/me", e.h.DeleteAccount)
+	api.POST("/me/password", e.h.ChangePassword)
+	api.POST("/me/email", e.h.ChangeEmail)
+	api.POST("/me/reauth/oidc", e.h.ReauthOIDC)
+	api.POST("/me/2fa/enroll", e.h.EnrollTwoFactor)
+	api.POST("/me/2fa/confirm", e.h.ConfirmTwoFactor)
+	api.POST("/me/2fa/disable", e.h.DisableTwoFactor)
+	api.GET("/tokens", e.h.ListAccessTokens)
+	api.POST("/tokens", e.h.CreateAccessToken)
+	api.DELETE("/tokens/:id", e.h.DeleteAccessToken)
+	e.router = r
+	return e
+}
+
+// auth stands in for AuthMiddleware of package main, accepting the access tokens
+// the handler issued as long as their session was not logged out
+func (e *testEnv) auth(c *gin.Context) {
+	claims, err := e.h.opts.Keys.Verify(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	if revoked, _ := e.store.IsTokenRevoked(c.Request.Context(), claims.ID); revoked {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+		return
+	}
+	c.Set("user_id", claims.UserID)
+	c.Set("session_id", claims.SessionID)
+	c.Next()
+}
+
+// do sends a request with body encoded as JSON, if any, and token as bearer, if set
+func (e *testEnv) do(method, path, token string, body any) *httptest.ResponseRecorder {
+	e.t.Helper()
+	var b []byte
+	if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			e.t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(b))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, req)
+	return w
+}
+
+// expect fails the test unless the response has status, and decodes its body into v if given
+func expect(t *testing.T, w *httptest.ResponseRecorder, status int, v any) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, status, w.Body)
+	}
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("decoding %s: %v", w.Body, err)
+		}
+	}
+}
+
+// signUp stores a user with testPassword and returns it with the access token of a login
+func (e *testEnv) signUp(email string) (*models.User, string) {
+	e.t.Helper()
+	// The lowest cost keeps the tests fast; logins compare against any cost
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	user := models.User{
+		Username:  strings.Split(email, "@")[0],
+		Email:     email,
+		Password:  string(hash),
+		TimeZone:  models.DefaultTimeZone,
+		CreatedAt: testNow,
+	}
+	if err := e.store.CreateUser(context.Background(), &user); err != nil {
+		e.t.Fatal(err)
+	}
+
+	return &user, e.login(email).Token
+}
+
+// login logs in with testPassword, starting a new session
+func (e *testEnv) login(email string) loginResponse {
+	e.t.Helper()
+	var login loginResponse
+	expect(e.t, e.do("POST", "/login", "", gin.H{"email": email, "password": testPassword}), http.StatusOK, &login)
+	return login
+}
+
+// createHabit creates a habit through the API
+func (e *testEnv) createHabit(token string, habit gin.H) models.Habit {
+	e.t.Helper()
+	var h models.Habit
+	expect(e.t, e.do("POST", "/habits", token, habit), http.StatusCreated, &h)
+	return h
+}
+
+// backdate moves a habit's creation back to created, as if it was made then
+func (e *testEnv) backdate(habitID int, created time.Time) {
+	e.store.mu.Lock()
+	defer e.store.mu.Unlock()
+	e.store.habits[habitID].CreatedAt = created
+}
+
+// complete records the habit as done on each of days
+func (e *testEnv) complete(token string, habitID int, days ...string) {
+	e.t.Helper()
+	for _, day := range days {
+		expect(e.t, e.do("POST", habitPath(habitID, "?date="+day), token, nil), http.StatusOK, nil)
+	}
+}
+
+// day returns the date offset days from testNow
+func day(offset int) string {
+	return testNow.AddDate(0, 0, offset).Format("2006-01-02")
+}
+
+// habitPath returns the path of a habit followed by rest
+func habitPath(habitID int, rest string) string {
+	return fmt.Sprintf("/habits/%d%s", habitID, rest)
+}
+
+// fakeMailer keeps the messages it is asked to send
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (m *fakeMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// lastToken returns the token of the link in the last message sent to to
+func (m *fakeMailer) lastToken(t *testing.T, to string) string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.sent) - 1; i >= 0; i-- {
+		if m.sent[i].To != to {
+			continue
+		}
+		for _, field := range strings.Fields(m.sent[i].Body) {
+			if u, err := url.Parse(field); err == nil && u.Query().Get("token") != "" {
+				return u.Query().Get("token")
+			}
+		}
+	}
+	t.Fatalf("no link was mailed to %s", to)
+	return ""
+}
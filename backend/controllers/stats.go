@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/stats"
+	"habit-tracker/backend/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rollingWindows are the lengths in days of the rolling completion rates, today included
+var rollingWindows = []int{7, 30, 90}
+
+// habitStats are the statistics of one habit
+type habitStats struct {
+	periods []stats.Period
+	rolling map[string]stats.Counts
+}
+
+// GET /habits/:id/stats?granularity=week|month|year
+func (h *Handler) GetHabitStats(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	habitID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid habit ID"})
+		return
+	}
+	granularity, err := models.ParseGranularity(c.Query("granularity"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	habit, err := h.store.GetHabit(ctx, habitID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch habit"})
+		return
+	}
+
+	loc, err := h.userLocation(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	all, err := h.computeStats(ctx, userID, habitID, []models.Habit{*habit}, granularity, loc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute statistics"})
+		return
+	}
+
+	response := statsResponse(granularity, all[0].periods, all[0].rolling, h.today(loc))
+	response["habit_id"] = habit.ID
+	response["title"] = habit.Title
+	c.JSON(http.StatusOK, response)
+}
+
+// GET /habits/stats?granularity=week|month|year - the statistics of all habits together
+func (h *Handler) GetStats(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	granularity, err := models.ParseGranularity(c.Query("granularity"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	habits, err := h.store.ListHabits(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch habits"})
+		return
+	}
+	loc, err := h.userLocation(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	all, err := h.computeStats(ctx, userID, 0, habits, granularity, loc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute statistics"})
+		return
+	}
+
+	// Every habit counts as much as it was due, so a daily habit outweighs a weekly one
+	perHabit := make([][]stats.Period, len(all))
+	rolling := make(map[string]stats.Counts)
+	for _, days := range rollingWindows {
+		rolling[rollingKey(days)] = stats.Counts{}
+	}
+	for i, hs := range all {
+		perHabit[i] = hs.periods
+		for window, counts := range hs.rolling {
+			rolling[window] = stats.Sum(rolling[window], counts)
+		}
+	}
+
+	response := statsResponse(granularity, stats.Merge(granularity, perHabit), rolling, h.today(loc))
+	response["total_habits"] = len(habits)
+	c.JSON(http.StatusOK, response)
+}
+
+// computeStats returns the statistics of each of habits, in order. habitID restricts
+// the queries to one habit, or is 0 when habits are all the user's habits.
+func (h *Handler) computeStats(ctx context.Context, userID, habitID int, habits []models.Habit, g models.Granularity, loc *time.Location) ([]habitStats, error) {
+	counts, err := h.store.CompletionsByPeriod(ctx, userID, habitID, g)
+	if err != nil {
+		return nil, err
+	}
+	byPeriod := make(map[int]map[time.Time]int)
+	first := make(map[int]time.Time)
+	for _, pc := range counts {
+		if byPeriod[pc.HabitID] == nil {
+			byPeriod[pc.HabitID] = make(map[time.Time]int)
+		}
+		byPeriod[pc.HabitID][pc.Period] = pc.Count
+		if f, ok := first[pc.HabitID]; !ok || pc.First.Before(f) {
+			first[pc.HabitID] = pc.First
+		}
+	}
+
+	today := h.today(loc)
+	result := make([]habitStats, len(habits))
+	starts := make([]time.Time, len(habits))
+	for i := range habits {
+		habit := &habits[i]
+		starts[i] = stats.Start(habit, loc, first[habit.ID])
+		result[i] = habitStats{
+			periods: stats.Periods(habit, g, starts[i], today, byPeriod[habit.ID]),
+			rolling: make(map[string]stats.Counts),
+		}
+	}
+
+	for _, days := range rollingWindows {
+		from := today.AddDate(0, 0, 1-days)
+		counted, err := h.store.CompletionsBetween(ctx, userID, habitID, from, today)
+		if err != nil {
+			return nil, err
+		}
+		window := rollingKey(days)
+		for i := range habits {
+			result[i].rolling[window] = stats.Window(&habits[i], starts[i], from, today, counted[habits[i].ID])
+		}
+	}
+	return result, nil
+}
+
+// rollingKey names a rolling window in responses, such as "7d"
+func rollingKey(days int) string {
+	return fmt.Sprintf("%dd", days)
+}
+
+// statsResponse writes periods and rolling rates with the best and worst finished period
+func statsResponse(g models.Granularity, periods []stats.Period, rolling map[string]stats.Counts, today time.Time) gin.H {
+	best, worst := stats.BestAndWorst(periods, today)
+	return gin.H{
+		"granularity":  g,
+		"periods":      periods,
+		"rolling":      rolling,
+		"best_period":  best,
+		"worst_period": worst,
+	}
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"habit-tracker/backend/models"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// periodResponse is one period of GET /habits/:id/stats
+type periodResponse struct {
+	Start     string  `json:"start"`
+	End       string  `json:"end"`
+	Completed int     `json:"completed"`
+	Due       int     `json:"due"`
+	Rate      float64 `json:"rate"`
+}
+
+func TestHabitStatsByWeek(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	// Created on the Monday of last week
+	monday := models.GranularityWeek.Truncate(testNow).AddDate(0, 0, -7)
+	habit := e.createHabit(token, gin.H{"title": "Gym", "schedule": gin.H{"type": "times_per_week", "times": 3}})
+	e.backdate(habit.ID, monday)
+	e.complete(token, habit.ID, monday.Format("2006-01-02"), monday.AddDate(0, 0, 2).Format("2006-01-02"), monday.AddDate(0, 0, 7).Format("2006-01-02"))
+
+	var stats struct {
+		Granularity string           `json:"granularity"`
+		Periods     []periodResponse `json:"periods"`
+		BestPeriod  *periodResponse  `json:"best_period"`
+	}
+	expect(t, e.do("GET", habitPath(habit.ID, "/stats?granularity=week"), token, nil), http.StatusOK, &stats)
+
+	if stats.Granularity != "week" || len(stats.Periods) != 2 {
+		t.Fatalf("stats = %+v, want two weeks", stats)
+	}
+	first := monday.Format("2006-01-02")
+	if p := stats.Periods[0]; p.Start != first || p.End != monday.AddDate(0, 0, 6).Format("2006-01-02") || p.Completed != 2 || p.Due != 3 {
+		t.Errorf("first week = %+v, want 2 of 3 in the week from %s", p, first)
+	}
+	if stats.BestPeriod == nil || stats.BestPeriod.Start != first {
+		t.Errorf("best period = %+v, want the finished first week", stats.BestPeriod)
+	}
+
+	expect(t, e.do("GET", habitPath(habit.ID, "/stats?granularity=day"), token, nil), http.StatusBadRequest, nil)
+}
+
+func TestStatsOfAllHabits(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	read := e.createHabit(token, gin.H{"title": "Read"})
+	run := e.createHabit(token, gin.H{"title": "Run"})
+	e.backdate(read.ID, testNow.AddDate(0, 0, -6))
+	e.backdate(run.ID, testNow.AddDate(0, 0, -6))
+	e.complete(token, read.ID, day(-6), day(-5), day(-4), day(-3), day(-2), day(-1), day(0))
+
+	var stats struct {
+		Rolling map[string]struct {
+			Completed int `json:"completed"`
+			Due       int `json:"due"`
+		} `json:"rolling"`
+	}
+	expect(t, e.do("GET", "/habits/stats", token, nil), http.StatusOK, &stats)
+
+	if week := stats.Rolling["7d"]; week.Completed != 7 || week.Due != 14 {
+		t.Errorf("last 7 days = %+v, want 7 of 14 done", week)
+	}
+}
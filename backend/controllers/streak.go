@@ -1,17 +1,25 @@
 package controllers
 
 import (
-	"database/sql"
+	"context"
+	"errors"
+	"fmt"
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/store"
+	"habit-tracker/backend/streaks"
+	"io"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
-	
+
 	"github.com/gin-gonic/gin"
 )
 
-func CompleteHabit(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+// POST /habits/:id
+func (h *Handler) CompleteHabit(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
@@ -22,10 +30,28 @@ func CompleteHabit(c *gin.Context) {
 		return
 	}
 
+	habit, err := h.store.GetHabit(c.Request.Context(), id, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Habit not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch habit"})
+		return
+	}
+
+	// "Today" is the current day in the user's time zone
+	loc, err := h.userLocation(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user"})
+		return
+	}
+	today := h.today(loc)
+
 	// Get date from query parameter, default to today if not provided
 	dateStr := c.Query("date")
 	var completionDate time.Time
-	
+
 	if dateStr != "" {
 		// Parse the provided date
 		parsedDate, err := time.Parse("2006-01-02", dateStr)
@@ -33,195 +59,236 @@ func CompleteHabit(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
 			return
 		}
-		
-		// Don't allow future dates
-		if parsedDate.After(time.Now()) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot mark habit complete for future dates"})
+
+		// Don't allow future dates or dates older than the lookback
+		if !checkCompletionDate(c, parsedDate, today) {
 			return
 		}
-		
+
 		completionDate = parsedDate
 	} else {
 		// Default to today
-		completionDate = time.Now()
+		completionDate = today
 	}
 
 	completionDateStr := completionDate.Format("2006-01-02")
 
+	// Optional body carrying the amount done, required for numeric habits
+	var input struct {
+		Value *float64 `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if habit.Type == models.HabitNumeric {
+		h.recordValue(c, habit, completionDate, input.Value)
+		return
+	}
+
 	// Step 1: Insert into habit_completions
-	insertQuery := `
-		INSERT INTO habit_completions (habit_id, user_id, date_completed)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (habit_id, date_completed) DO NOTHING
-		RETURNING id
-	`
-
-	var completionID sql.NullInt64
-	err = db.QueryRow(insertQuery, id, userID, completionDateStr).Scan(&completionID)
-	if err != nil && err != sql.ErrNoRows {
+	inserted, err := h.store.AddCompletion(c.Request.Context(), id, userID, completionDate, h.clock.Now())
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to mark habit as complete", "details": err.Error()})
 		return
 	}
 
 	// Check if this was a duplicate (no new row inserted)
-	if !completionID.Valid {
+	if !inserted {
+		message := "Habit was already marked complete for this date"
+		if habit.Kind == models.HabitQuit {
+			message = "Slip was already recorded for this date"
+		}
 		c.JSON(http.StatusOK, gin.H{
-			"message": "Habit was already marked complete for this date",
-			"date": completionDateStr,
+			"message": message,
+			"date":    completionDateStr,
 		})
 		return
 	}
 
 	// Step 2: Recalculate streaks based on all completion dates
 	// This is more robust than the previous approach
-	err = recalculateStreaks(id, int(userID.(float64)))
+	_, err = h.recalculateStreaks(c.Request.Context(), habit, completionDate)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update streak", "details": err.Error()})
 		return
 	}
 
+	message := "Habit marked as complete with streak updated"
+	if habit.Kind == models.HabitQuit {
+		message = "Slip recorded with streak updated"
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Habit marked as complete with streak updated",
-		"date": completionDateStr,
+		"message": message,
+		"date":    completionDateStr,
 	})
 }
 
-// Helper function to recalculate streaks based on all completion dates
-func recalculateStreaks(habitID, userID int) error {
-	// Get all completion dates for this habit, sorted
-	rows, err := db.Query(`
-		SELECT date_completed
-		FROM habit_completions
-		WHERE habit_id = $1 AND user_id = $2
-		ORDER BY date_completed ASC
-	`, habitID, userID)
+// maxCompletionYears bounds how far back a completion may be recorded, so a mistyped
+// year cannot make streaks, strength and statistics walk centuries of empty days
+const maxCompletionYears = 10
 
-	if err != nil {
-		return err
+// earliestCompletion returns the oldest day a completion may be recorded for
+func earliestCompletion(today time.Time) time.Time {
+	return today.AddDate(-maxCompletionYears, 0, 0)
+}
+
+// checkCompletionDate writes a 400 response and returns false if date is in the
+// future or older than the lookback allows
+func checkCompletionDate(c *gin.Context, date, today time.Time) bool {
+	if date.After(today) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot mark habit complete for future dates"})
+		return false
 	}
-	defer rows.Close()
+	if earliest := earliestCompletion(today); date.Before(earliest) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Cannot record completions before %s", earliest.Format("2006-01-02"))})
+		return false
+	}
+	return true
+}
 
-	var dates []time.Time
-	for rows.Next() {
-		var d time.Time
-		if err := rows.Scan(&d); err == nil {
-			dates = append(dates, d)
-		}
+// recordValue adds an entry to a numeric habit's total for the day and updates its streak
+func (h *Handler) recordValue(c *gin.Context, habit *models.Habit, date time.Time, value *float64) {
+	if value == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "value is required for numeric habits"})
+		return
+	}
+	if *value < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "value cannot be negative"})
+		return
 	}
 
-	if len(dates) == 0 {
-		// No completions, remove streak record if it exists
-		_, err = db.Exec(`DELETE FROM habit_streaks WHERE habit_id = $1 AND user_id = $2`, habitID, userID)
-		return err
+	total, err := h.store.AccumulateCompletion(c.Request.Context(), habit.ID, habit.UserID, date, *value, h.clock.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record value", "details": err.Error()})
+		return
 	}
 
-	// Calculate current and longest streaks
-	currentStreak, longestStreak := calculateStreaks(dates)
-	lastCompleted := dates[len(dates)-1]
+	if _, err := h.recalculateStreaks(c.Request.Context(), habit, date); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update streak", "details": err.Error()})
+		return
+	}
 
-	// Upsert the streak record
-	upsertQuery := `
-		INSERT INTO habit_streaks (habit_id, user_id, current_streak, longest_streak, last_completed)
-		VALUES ($1, $2, $3, $4, $5)
-		ON CONFLICT (habit_id, user_id) 
-		DO UPDATE SET 
-			current_streak = EXCLUDED.current_streak,
-			longest_streak = EXCLUDED.longest_streak,
-			last_completed = EXCLUDED.last_completed
-	`
-
-	_, err = db.Exec(upsertQuery, habitID, userID, currentStreak, longestStreak, lastCompleted)
-	return err
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Value recorded with streak updated",
+		"date":      date.Format("2006-01-02"),
+		"value":     total,
+		"target":    habit.Target,
+		"unit":      habit.Unit,
+		"completed": habit.MeetsTarget(total),
+	})
 }
 
-// Helper function to calculate streaks from a sorted list of dates
-func calculateStreaks(dates []time.Time) (currentStreak, longestStreak int) {
-	if len(dates) == 0 {
-		return 0, 0
-	}
-
-	// Remove duplicates and ensure dates are normalized (date only, no time)
-	dateMap := make(map[string]bool)
-	var uniqueDates []time.Time
-	
-	for _, d := range dates {
-		dateStr := d.Format("2006-01-02")
-		if !dateMap[dateStr] {
-			dateMap[dateStr] = true
-			normalized := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, d.Location())
-			uniqueDates = append(uniqueDates, normalized)
+// completedDates returns the days on which the habit counts as done, oldest first.
+// For numeric habits that is only the days whose total meets the target.
+func (h *Handler) completedDates(ctx context.Context, habit *models.Habit) ([]time.Time, error) {
+	values, err := h.store.DailyValues(ctx, habit.ID, habit.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var dates []time.Time
+	for _, dv := range values {
+		if habit.MeetsTarget(dv.Value) {
+			dates = append(dates, dv.Date)
 		}
 	}
+	return dates, nil
+}
 
-	if len(uniqueDates) == 0 {
-		return 0, 0
+// computeStreaks runs the streak engine over a habit's completion dates (or slips)
+func (h *Handler) computeStreaks(habit *models.Habit, dates []time.Time, loc *time.Location) streaks.Result {
+	return streaks.Compute(streaks.Input{
+		Dates:    dates,
+		Kind:     habit.Kind,
+		Schedule: habit.Schedule,
+		Start:    habit.CreatedAt,
+		Location: loc,
+		Clock:    h.clock,
+	})
+}
+
+// Helper function to recalculate streaks based on all completion dates.
+// since is the earliest day whose completions changed, or zero if the habit itself
+// changed; strength scores are only rewritten from there on.
+// It returns the saved streak, or nil if the habit no longer has one.
+func (h *Handler) recalculateStreaks(ctx context.Context, habit *models.Habit, since time.Time) (*models.Streak, error) {
+	habitID, userID := habit.ID, habit.UserID
+
+	loc, err := h.userLocation(ctx, userID)
+	if err != nil {
+		return nil, err
 	}
 
-	// Calculate longest streak by finding consecutive dates
-	longestStreak = 1
-	currentStreakInLoop := 1
+	// Get all completion dates for this habit, sorted
+	dates, err := h.completedDates(ctx, habit)
+	if err != nil {
+		return nil, err
+	}
 
-	for i := 1; i < len(uniqueDates); i++ {
-		daysDiff := int(uniqueDates[i].Sub(uniqueDates[i-1]).Hours() / 24)
-		
-		if daysDiff == 1 {
-			// Consecutive day
-			currentStreakInLoop++
-		} else {
-			// Break in streak
-			if currentStreakInLoop > longestStreak {
-				longestStreak = currentStreakInLoop
-			}
-			currentStreakInLoop = 1
-		}
+	// Quit habits are tracked by slips, so having none is still a clean streak
+	if len(dates) == 0 && habit.Kind != models.HabitQuit {
+		// No completions, remove streak record and strength history if they exist
+		return nil, h.store.DeleteStreak(ctx, habitID, userID)
 	}
 
-	// Check if the last streak is the longest
-	if currentStreakInLoop > longestStreak {
-		longestStreak = currentStreakInLoop
+	result := h.computeStreaks(habit, dates, loc)
+	streak := models.Streak{
+		HabitID:       habitID,
+		UserID:        userID,
+		CurrentStreak: result.Current,
+		LongestStreak: result.Longest,
+		Strength:      result.Strength,
+	}
+	if len(dates) > 0 {
+		streak.LastCompleted = &dates[len(dates)-1]
 	}
 
-	// Calculate current streak (from most recent date backwards)
-	currentStreak = 1
-	today := time.Now()
-	lastDate := uniqueDates[len(uniqueDates)-1]
+	// Upsert the streak record
+	if err := h.store.SaveStreak(ctx, streak); err != nil {
+		return nil, err
+	}
+	if err := h.saveStrength(ctx, habit, since, result.StrengthHistory); err != nil {
+		return nil, err
+	}
+	return &streak, nil
+}
 
-	// Only count as current streak if the last completion was recent (today or yesterday)
-	daysSinceLastCompletion := int(today.Sub(lastDate).Hours() / 24)
-	if daysSinceLastCompletion > 1 {
-		currentStreak = 0
-	} else {
-		// Count backwards from the last date
-		for i := len(uniqueDates) - 2; i >= 0; i-- {
-			daysDiff := int(uniqueDates[i+1].Sub(uniqueDates[i]).Hours() / 24)
-			if daysDiff == 1 {
-				currentStreak++
-			} else {
-				break
-			}
+// saveStrength stores the days of history from since on, or all of them if since is
+// zero. Days after the last one stored are written too, which is how the nightly
+// recompute adds each new day, and so is that last day, as it may have been today
+// and still open.
+func (h *Handler) saveStrength(ctx context.Context, habit *models.Habit, since time.Time, history []models.DayStrength) error {
+	if len(history) == 0 {
+		return nil
+	}
+
+	if !since.IsZero() {
+		latest, err := h.store.LatestStrengthDay(ctx, habit.ID, habit.UserID)
+		if err != nil {
+			return err
+		}
+		if latest.IsZero() || latest.Before(since) {
+			since = latest
 		}
 	}
 
-	return currentStreak, longestStreak
+	first := sort.Search(len(history), func(i int) bool {
+		return !history[i].Date.Before(since)
+	})
+	return h.store.SaveStrength(ctx, habit.ID, habit.UserID, history[0].Date, history[first:])
 }
 
 // GET /habits/completed
-func GetCompletedHabits(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+func (h *Handler) GetCompletedHabits(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	query := `
-		SELECT hc.id, hc.habit_id, hc.date_completed, h.title, h.description
-		FROM habit_completions hc
-		JOIN habits h ON hc.habit_id = h.id
-		WHERE hc.user_id = $1
-		ORDER BY hc.date_completed DESC
-	`
-
-	rows, err := db.Query(query, userID)
+	completed, err := h.store.ListCompletedHabits(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "failed to fetch completed habits",
@@ -230,28 +297,14 @@ func GetCompletedHabits(c *gin.Context) {
 		return
 	}
 
-	defer rows.Close()
-
 	var completedHabits []gin.H
-
-	for rows.Next() {
-		var id int
-		var habitID int
-		var dateCompleted time.Time
-		var title, description string
-
-		err := rows.Scan(&id, &habitID, &dateCompleted, &title, &description)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error reading completed habit data"})
-			return
-		}
-
+	for _, ch := range completed {
 		completedHabits = append(completedHabits, gin.H{
-			"id":             id,
-			"habit_id":       habitID,
-			"title":          title,
-			"description":    description,
-			"date_completed": dateCompleted.Format("2006-01-02"),
+			"id":             ch.ID,
+			"habit_id":       ch.HabitID,
+			"title":          ch.Title,
+			"description":    ch.Description,
+			"date_completed": ch.DateCompleted.Format("2006-01-02"),
 		})
 	}
 
@@ -259,56 +312,45 @@ func GetCompletedHabits(c *gin.Context) {
 }
 
 // GET /habits/streak
-func GetHabitsStreaks(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+func (h *Handler) GetHabitsStreaks(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	// Query to fetch habit info with streak data
-	query := `
-		SELECT h.id, h.title, h.description,
-		       s.current_streak, s.longest_streak, s.last_completed
-		FROM habits h
-		LEFT JOIN habit_streaks s ON h.id = s.habit_id
-		WHERE h.user_id = $1
-	`
-
-	rows, err := db.Query(query, userID)
+	// Fetch habit info with streak data
+	habitStreaks, err := h.store.ListHabitStreaks(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch habits with streaks"})
 		return
 	}
-	defer rows.Close()
 
 	var results []gin.H
-
-	for rows.Next() {
-		var id int
-		var title, description string
-		var currentStreak, longestStreak sql.NullInt64
-		var lastCompleted sql.NullTime
-
-		if err := rows.Scan(&id, &title, &description, &currentStreak, &longestStreak, &lastCompleted); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error reading result"})
-			return
-		}
-
+	for _, hs := range habitStreaks {
+		var currentStreak, longestStreak int
+		var strength float64
 		lastCompletedStr := ""
-		if lastCompleted.Valid {
-			lastCompletedStr = lastCompleted.Time.Format("2006-01-02")
+		if hs.Streak != nil {
+			currentStreak = hs.Streak.CurrentStreak
+			longestStreak = hs.Streak.LongestStreak
+			strength = hs.Streak.Strength
+			if hs.Streak.LastCompleted != nil {
+				lastCompletedStr = hs.Streak.LastCompleted.Format("2006-01-02")
+			}
 		}
 
 		results = append(results, gin.H{
-			"habit_id":       id,
-			"title":          title,
-			"description":    description,
-			"current_streak": currentStreak.Int64,
-			"longest_streak": longestStreak.Int64,
+			"habit_id":       hs.Habit.ID,
+			"title":          hs.Habit.Title,
+			"description":    hs.Habit.Description,
+			"kind":           hs.Habit.Kind,
+			"current_streak": currentStreak,
+			"longest_streak": longestStreak,
+			"strength":       models.RoundStrength(strength),
 			"last_completed": lastCompletedStr,
 		})
 	}
 
 	c.JSON(http.StatusOK, results)
-}
\ No newline at end of file
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streakResponse is one entry of GET /habits/streak
+type streakResponse struct {
+	HabitID       int    `json:"habit_id"`
+	CurrentStreak int    `json:"current_streak"`
+	LongestStreak int    `json:"longest_streak"`
+	LastCompleted string `json:"last_completed"`
+}
+
+func TestCompleteHabitUpdatesStreak(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Read"})
+
+	e.complete(token, habit.ID, day(-2), day(-1))
+	// Without a date it is today
+	expect(t, e.do("POST", habitPath(habit.ID, ""), token, nil), http.StatusOK, nil)
+
+	var streaks []streakResponse
+	expect(t, e.do("GET", "/habits/streak", token, nil), http.StatusOK, &streaks)
+	if len(streaks) != 1 {
+		t.Fatalf("got %d streaks, want 1", len(streaks))
+	}
+	if s := streaks[0]; s.CurrentStreak != 3 || s.LongestStreak != 3 || s.LastCompleted != day(0) {
+		t.Errorf("streak = %+v, want 3 days up to today", s)
+	}
+
+	var again struct {
+		Message string `json:"message"`
+	}
+	expect(t, e.do("POST", habitPath(habit.ID, "?date="+day(0)), token, nil), http.StatusOK, &again)
+	if again.Message != "Habit was already marked complete for this date" {
+		t.Errorf("completing twice says %q", again.Message)
+	}
+	expect(t, e.do("POST", habitPath(habit.ID, "?date="+day(1)), token, nil), http.StatusBadRequest, nil)
+
+	var completed []struct {
+		Date string `json:"date_completed"`
+	}
+	expect(t, e.do("GET", "/habits/completed", token, nil), http.StatusOK, &completed)
+	if len(completed) != 3 || completed[0].Date != day(0) || completed[2].Date != day(-2) {
+		t.Errorf("completed = %+v, want the three days newest first", completed)
+	}
+}
+
+func TestScheduledHabitStreakCountsPeriods(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Water plants", "schedule": gin.H{"type": "every_n_days", "interval": 2}})
+
+	// Each two-day window from the first completion on needs one
+	e.complete(token, habit.ID, day(-6), day(-2), day(0))
+
+	var streaks []streakResponse
+	expect(t, e.do("GET", "/habits/streak", token, nil), http.StatusOK, &streaks)
+	if s := streaks[0]; s.CurrentStreak != 2 || s.LongestStreak != 2 {
+		t.Errorf("streak = %+v, want 2 windows since the one missed around %s", s, day(-4))
+	}
+}
+
+func TestCompleteNumericHabitAddsUpValues(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Water", "type": "numeric", "target": 8, "unit": "glasses"})
+
+	expect(t, e.do("POST", habitPath(habit.ID, ""), token, nil), http.StatusBadRequest, nil)
+
+	type recorded struct {
+		Value     float64 `json:"value"`
+		Completed bool    `json:"completed"`
+	}
+	var first, second recorded
+	expect(t, e.do("POST", habitPath(habit.ID, ""), token, gin.H{"value": 5}), http.StatusOK, &first)
+	expect(t, e.do("POST", habitPath(habit.ID, ""), token, gin.H{"value": 4}), http.StatusOK, &second)
+	if first != (recorded{5, false}) || second != (recorded{9, true}) {
+		t.Errorf("recorded %+v then %+v, want 5 short of the target then 9 meeting it", first, second)
+	}
+}
+
+func TestQuitHabitCountsCleanDays(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Smoking", "kind": "quit"})
+
+	var streaks []streakResponse
+	expect(t, e.do("GET", "/habits/streak", token, nil), http.StatusOK, &streaks)
+	if s := streaks[0]; s.CurrentStreak != 1 || s.LastCompleted != "" {
+		t.Errorf("streak = %+v, want today clean", s)
+	}
+
+	// A slip logged for before the habit was created moves the start back
+	e.complete(token, habit.ID, day(-3))
+	expect(t, e.do("GET", "/habits/streak", token, nil), http.StatusOK, &streaks)
+	if s := streaks[0]; s.CurrentStreak != 3 || s.LongestStreak != 3 || s.LastCompleted != day(-3) {
+		t.Errorf("streak = %+v, want 3 clean days since the slip", s)
+	}
+}
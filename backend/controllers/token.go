@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"habit-tracker/backend/authtoken"
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/store"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// accessTokenTTL is kept short because access tokens are only checked against
+	// the revocation list, never re-issued
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// randomToken returns n random bytes encoded for use in URLs and headers
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// signInternal signs a short-lived token that only this backend reads, such as the
+// pending two-factor login. These always use the secret NewHandler was given, never
+// the published keys.
+func (h *Handler) signInternal(claims jwt.Claims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(h.jwtSecret)
+}
+
+// parseInternal verifies a token from signInternal into claims
+func (h *Handler) parseInternal(raw string, claims jwt.Claims) error {
+	_, err := jwt.ParseWithClaims(raw, claims, func(*jwt.Token) (any, error) {
+		return h.jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	return err
+}
+
+// GET /.well-known/jwks.json
+func (h *Handler) JWKS(c *gin.Context) {
+	// Other services cache the keys; rotations keep the old key listed for longer than this
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.opts.Keys.JWKS())
+}
+
+// issueTokens creates a session in familyID for user and returns the token response.
+// If previous is set the new session replaces it as a refresh-token rotation.
+func (h *Handler) issueTokens(ctx context.Context, user *models.User, familyID string, previous *models.Session) (gin.H, error) {
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	jti, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		UserID:          user.ID,
+		FamilyID:        familyID,
+		TokenHash:       store.HashToken(refreshToken),
+		AccessJTI:       jti,
+		AccessExpiresAt: now.Add(accessTokenTTL),
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(refreshTokenTTL),
+	}
+	if previous != nil {
+		err = h.store.RotateSession(ctx, previous.ID, &session)
+	} else {
+		err = h.store.CreateSession(ctx, &session)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The access token names its session so logout can revoke it
+	accessToken, err := h.opts.Keys.Sign(&authtoken.Claims{
+		UserID:    user.ID,
+		Email:     user.Email,
+		SessionID: session.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(user.ID),
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(session.AccessExpiresAt),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"token":         accessToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(accessTokenTTL.Seconds()),
+		"refresh_token": refreshToken,
+	}, nil
+}
+
+// POST /token/refresh
+func (h *Handler) RefreshToken(c *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	session, err := h.store.GetSessionByTokenHash(ctx, store.HashToken(input.RefreshToken))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch session"})
+		return
+	}
+
+	// A refresh token is only good once; seeing it again means it was stolen
+	if session.RevokedAt != nil {
+		h.revokeFamily(ctx, session)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has been revoked"})
+		return
+	}
+	if time.Now().After(session.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token expired"})
+		return
+	}
+
+	user, err := h.store.GetUserByID(ctx, session.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	tokens, err := h.issueTokens(ctx, user, session.FamilyID, session)
+	if errors.Is(err, store.ErrConflict) {
+		// Lost a race with another refresh using the same token
+		h.revokeFamily(ctx, session)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has been revoked"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *Handler) revokeFamily(ctx context.Context, session *models.Session) {
+	log.Printf("⚠️  Refresh token reuse for user %d, revoking session family %s", session.UserID, session.FamilyID)
+	if err := h.store.RevokeSessionFamily(ctx, session.FamilyID); err != nil {
+		log.Printf("❌ Failed to revoke session family %s: %v", session.FamilyID, err)
+	}
+}
+
+// POST /logout
+func (h *Handler) Logout(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	sessionID, ok := currentSessionID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.store.RevokeSession(c.Request.Context(), sessionID, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// POST /logout-all
+func (h *Handler) LogoutAll(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.store.RevokeUserSessions(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions"})
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"habit-tracker/backend/authtoken"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRefreshTokenRotation(t *testing.T) {
+	e := newTestEnv(t)
+	e.signUp("ann@example.com")
+
+	login := e.login("ann@example.com")
+
+	var refreshed loginResponse
+	expect(t, e.do("POST", "/token/refresh", "", gin.H{"refresh_token": login.RefreshToken}), http.StatusOK, &refreshed)
+	if refreshed.RefreshToken == "" || refreshed.RefreshToken == login.RefreshToken {
+		t.Fatalf("refresh returned refresh token %q, want a new one", refreshed.RefreshToken)
+	}
+	expect(t, e.do("GET", "/me", refreshed.Token, nil), http.StatusOK, nil)
+
+	// Reusing the first token looks like theft and ends the whole family
+	expect(t, e.do("POST", "/token/refresh", "", gin.H{"refresh_token": login.RefreshToken}), http.StatusUnauthorized, nil)
+	expect(t, e.do("POST", "/token/refresh", "", gin.H{"refresh_token": refreshed.RefreshToken}), http.StatusUnauthorized, nil)
+	expect(t, e.do("GET", "/me", refreshed.Token, nil), http.StatusUnauthorized, nil)
+}
+
+func TestLogoutRevokesOnlyItsSession(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	other := e.login("ann@example.com")
+
+	expect(t, e.do("POST", "/logout", token, nil), http.StatusOK, nil)
+	expect(t, e.do("GET", "/me", token, nil), http.StatusUnauthorized, nil)
+	expect(t, e.do("GET", "/me", other.Token, nil), http.StatusOK, nil)
+
+	expect(t, e.do("POST", "/logout-all", other.Token, nil), http.StatusOK, nil)
+	expect(t, e.do("GET", "/me", other.Token, nil), http.StatusUnauthorized, nil)
+}
+
+func TestJWKSIsEmptyForSharedSecret(t *testing.T) {
+	e := newTestEnv(t)
+
+	var set authtoken.JWKS
+	expect(t, e.do("GET", "/.well-known/jwks.json", "", nil), http.StatusOK, &set)
+	if len(set.Keys) != 0 {
+		t.Errorf("keys = %+v, want none for HS256", set.Keys)
+	}
+}
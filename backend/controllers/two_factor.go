@@ -0,0 +1,323 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/store"
+	"habit-tracker/backend/totp"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	// totpIssuer is the account name shown in authenticator apps
+	totpIssuer = "Habit Tracker"
+	// mfaTokenTTL is how long a user has to enter their code after the password
+	mfaTokenTTL = 5 * time.Minute
+	// mfaTokenType marks JWTs that only allow the second login step
+	mfaTokenType      = "mfa_pending"
+	recoveryCodeCount = 10
+)
+
+// mfaClaims are the contents of the token for the second login step
+type mfaClaims struct {
+	UserID int    `json:"user_id"`
+	Type   string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// requireMFA answers a correct password with a short-lived token for POST /login/mfa
+func (h *Handler) requireMFA(c *gin.Context, user *models.User) {
+	mfaToken, err := h.signInternal(&mfaClaims{
+		UserID: user.ID,
+		Type:   mfaTokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(mfaTokenTTL)),
+		},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Two-factor code required",
+		"mfa_required": true,
+		"mfa_token":    mfaToken,
+		"expires_in":   int(mfaTokenTTL.Seconds()),
+	})
+}
+
+// POST /login/mfa
+func (h *Handler) VerifyMFA(c *gin.Context) {
+	var input struct {
+		MFAToken     string `json:"mfa_token" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := h.parseMFAToken(input.MFAToken)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+
+	key := fmt.Sprintf("mfa:%d", userID)
+	if !h.allow(c, key, loginAccountLimit) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := h.store.GetUserByID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	tf, err := h.store.GetTwoFactor(ctx, userID)
+	if err != nil || !tf.Enabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+
+	valid, err := h.checkSecondFactor(ctx, tf, input.Code, input.RecoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check code"})
+		return
+	}
+	if !valid {
+		h.loginFailed(c, key, "Invalid two-factor code")
+		return
+	}
+	if err := h.opts.Limiter.ResetFailures(ctx, key); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset login failures"})
+		return
+	}
+
+	h.completeLogin(c, user)
+}
+
+// parseMFAToken returns the user of a valid token issued by requireMFA
+func (h *Handler) parseMFAToken(tokenString string) (int, bool) {
+	var claims mfaClaims
+	if err := h.parseInternal(tokenString, &claims); err != nil || claims.Type != mfaTokenType {
+		return 0, false
+	}
+	return claims.UserID, true
+}
+
+// checkSecondFactor accepts either a current TOTP code, once, or an unused recovery code
+func (h *Handler) checkSecondFactor(ctx context.Context, tf *models.TwoFactor, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, ok := totp.Validate(tf.Secret, code, h.clock.Now())
+		if !ok {
+			return false, nil
+		}
+		return h.store.UseTOTPStep(ctx, tf.UserID, step)
+	}
+	if recoveryCode != "" {
+		return h.store.UseRecoveryCode(ctx, tf.UserID, store.HashToken(normalizeRecoveryCode(recoveryCode)))
+	}
+	return false, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+}
+
+// newRecoveryCodes returns fresh codes such as "k3v9q-7xw2m" and their hashes
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(secret[:10])
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = store.HashToken(normalizeRecoveryCode(codes[i]))
+	}
+	return codes, hashes, nil
+}
+
+// POST /me/2fa/enroll
+func (h *Handler) EnrollTwoFactor(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := h.store.GetUserByID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	existing, err := h.store.GetTwoFactor(ctx, userID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch two-factor settings"})
+		return
+	}
+	if existing != nil && existing.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	// Enrolling again before confirming simply replaces the pending secret
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	tf := models.TwoFactor{UserID: userID, Secret: secret, CreatedAt: time.Now()}
+	if err := h.store.SaveTwoFactor(ctx, &tf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save two-factor settings"})
+		return
+	}
+
+	uri := totp.URI(totpIssuer, user.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Scan the QR code, then confirm with a code from your authenticator",
+		"secret":      secret,
+		"otpauth_uri": uri,
+		"qr_code":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+// POST /me/2fa/confirm
+func (h *Handler) ConfirmTwoFactor(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	tf, err := h.store.GetTwoFactor(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start enrolment first"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch two-factor settings"})
+		return
+	}
+	if tf.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	step, ok := totp.Validate(tf.Secret, input.Code, h.clock.Now())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	if err := h.store.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save recovery codes"})
+		return
+	}
+
+	tf.Enabled = true
+	tf.LastStep = step
+	if err := h.store.SaveTwoFactor(ctx, tf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save two-factor settings"})
+		return
+	}
+
+	// The recovery codes are only ever shown here
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// POST /me/2fa/disable
+func (h *Handler) DisableTwoFactor(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Shares the key of the login step, so a stolen session cannot guess codes
+	// here after the login lockout kicked in
+	key := fmt.Sprintf("mfa:%d", userID)
+	if !h.allow(c, key, loginAccountLimit) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	tf, err := h.store.GetTwoFactor(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && !tf.Enabled) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch two-factor settings"})
+		return
+	}
+
+	valid, err := h.checkSecondFactor(ctx, tf, input.Code, input.RecoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check code"})
+		return
+	}
+	if !valid {
+		h.attemptFailed(c, key, http.StatusForbidden, "Invalid two-factor code")
+		return
+	}
+	if err := h.opts.Limiter.ResetFailures(ctx, key); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset login failures"})
+		return
+	}
+
+	if err := h.store.DeleteTwoFactor(ctx, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"habit-tracker/backend/totp"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// enableTwoFactor enrols and confirms TOTP for the user of token and returns the
+// secret with the recovery codes
+func (e *testEnv) enableTwoFactor(token string) (string, []string) {
+	e.t.Helper()
+	var enrolled struct {
+		Secret string `json:"secret"`
+	}
+	expect(e.t, e.do("POST", "/me/2fa/enroll", token, nil), http.StatusOK, &enrolled)
+
+	var confirmed struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	expect(e.t, e.do("POST", "/me/2fa/confirm", token, gin.H{"code": "000000"}), http.StatusBadRequest, nil)
+	expect(e.t, e.do("POST", "/me/2fa/confirm", token, gin.H{"code": totpCode(e.t, enrolled.Secret, 0)}), http.StatusOK, &confirmed)
+	if len(confirmed.RecoveryCodes) != recoveryCodeCount {
+		e.t.Fatalf("got %d recovery codes, want %d", len(confirmed.RecoveryCodes), recoveryCodeCount)
+	}
+	return enrolled.Secret, confirmed.RecoveryCodes
+}
+
+// totpCode returns the code of the TOTP period offset steps from testNow
+func totpCode(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	code, err := totp.Code(secret, totp.Step(testNow)+offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	secret, codes := e.enableTwoFactor(token)
+	expect(t, e.do("POST", "/me/2fa/enroll", token, nil), http.StatusConflict, nil)
+
+	login := e.login("ann@example.com")
+	if !login.MFARequired || login.MFAToken == "" || login.Token != "" {
+		t.Fatalf("login = %+v, want only a token for the second step", login)
+	}
+	// The pending token is no access token
+	expect(t, e.do("GET", "/me", login.MFAToken, nil), http.StatusUnauthorized, nil)
+
+	// The code confirming enrolment cannot be replayed, the next one works once
+	step := gin.H{"mfa_token": login.MFAToken, "code": totpCode(t, secret, 0)}
+	expect(t, e.do("POST", "/login/mfa", "", step), http.StatusUnauthorized, nil)
+	step["code"] = totpCode(t, secret, 1)
+	expect(t, e.do("POST", "/login/mfa", "", step), http.StatusOK, &login)
+	expect(t, e.do("GET", "/me", login.Token, nil), http.StatusOK, nil)
+	expect(t, e.do("POST", "/login/mfa", "", step), http.StatusUnauthorized, nil)
+
+	// Recovery codes work once, typed in any case and spacing
+	step = gin.H{"mfa_token": login.MFAToken, "recovery_code": " " + codes[0] + " "}
+	expect(t, e.do("POST", "/login/mfa", "", step), http.StatusOK, nil)
+	expect(t, e.do("POST", "/login/mfa", "", step), http.StatusUnauthorized, nil)
+}
+
+func TestDisableTwoFactor(t *testing.T) {
+	e := newTestEnv(t)
+	user, token := e.signUp("ann@example.com")
+	_, codes := e.enableTwoFactor(token)
+
+	expect(t, e.do("POST", "/me/2fa/disable", token, gin.H{"recovery_code": "wrong-code"}), http.StatusForbidden, nil)
+	expect(t, e.do("POST", "/me/2fa/disable", token, gin.H{"recovery_code": codes[1]}), http.StatusOK, nil)
+	if _, ok := e.store.twoFactor[user.ID]; ok {
+		t.Error("two-factor settings were not removed")
+	}
+	if _, ok := e.store.recoveryCodes[user.ID]; ok {
+		t.Error("recovery codes were not removed")
+	}
+
+	login := e.login("ann@example.com")
+	if login.MFARequired || login.Token == "" {
+		t.Errorf("login = %+v, want a session straight away", login)
+	}
+	expect(t, e.do("POST", "/me/2fa/disable", token, gin.H{"recovery_code": codes[2]}), http.StatusBadRequest, nil)
+}
@@ -0,0 +1,370 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"habit-tracker/backend/mailer"
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/store"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// userLocation returns the time zone in which the user's days are counted
+func (h *Handler) userLocation(ctx context.Context, userID int) (*time.Location, error) {
+	user, err := h.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return user.Location(), nil
+}
+
+// GET /me
+func (h *Handler) GetProfile(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	user, err := h.store.GetUserByID(c.Request.Context(), userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	user.Password = ""
+	c.JSON(http.StatusOK, user)
+}
+
+// PATCH /me
+func (h *Handler) UpdateProfile(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Username *string `json:"username"`
+		TimeZone *string `json:"time_zone"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.store.GetUserByID(c.Request.Context(), userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	if input.Username != nil {
+		username := strings.TrimSpace(*input.Username)
+		if username == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "username cannot be empty"})
+			return
+		}
+		user.Username = username
+	}
+
+	zoneChanged := false
+	if input.TimeZone != nil {
+		if _, err := models.ParseTimeZone(*input.TimeZone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		zoneChanged = *input.TimeZone != user.TimeZone
+		user.TimeZone = *input.TimeZone
+	}
+
+	if err := h.store.UpdateUser(c.Request.Context(), user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	// "Today" moved, which can end or revive a current streak
+	if zoneChanged {
+		if err := h.recalculateUserStreaks(c.Request.Context(), user.ID, time.Time{}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update streaks"})
+			return
+		}
+	}
+
+	user.Password = ""
+	c.JSON(http.StatusOK, user)
+}
+
+const (
+	// reauthTokenTTL is how long a fresh sign-in with the identity provider stands
+	// in for the password
+	reauthTokenTTL  = 5 * time.Minute
+	reauthTokenType = "reauth"
+)
+
+// reauthClaims are the contents of the token POST /me/reauth/oidc returns
+type reauthClaims struct {
+	UserID int    `json:"user_id"`
+	Type   string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// authenticate loads the current user and checks password against theirs, or if
+// reauthToken is set that it was issued to them. Users created by single sign-on
+// have no password they know and re-authenticate with the provider instead.
+// Wrong passwords count towards the login lockout of the account.
+// It writes the error response and returns nil if any check fails.
+func (h *Handler) authenticate(c *gin.Context, password, reauthToken string) *models.User {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil
+	}
+
+	user, err := h.store.GetUserByID(c.Request.Context(), userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return nil
+	}
+
+	if reauthToken != "" {
+		var claims reauthClaims
+		if err := h.parseInternal(reauthToken, &claims); err != nil || claims.Type != reauthTokenType || claims.UserID != user.ID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired re-authentication"})
+			return nil
+		}
+		return user
+	}
+
+	// Shares the key of the login step, so a stolen session cannot guess the
+	// password here after the login lockout kicked in
+	key := "login:" + strings.ToLower(user.Email)
+	if !h.allow(c, key, loginAccountLimit) {
+		return nil
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		h.attemptFailed(c, key, http.StatusForbidden, "Current password is incorrect")
+		return nil
+	}
+	if err := h.opts.Limiter.ResetFailures(c.Request.Context(), key); err != nil {
+		log.Printf("⚠️  Failed to reset login failures: %v", err)
+	}
+	return user
+}
+
+// POST /me/password
+func (h *Handler) ChangePassword(c *gin.Context) {
+	var input struct {
+		CurrentPassword string `json:"current_password" binding:"required_without=ReauthToken"`
+		ReauthToken     string `json:"reauth_token"`
+		NewPassword     string `json:"new_password" binding:"required,min=8"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := h.authenticate(c, input.CurrentPassword, input.ReauthToken)
+	if user == nil {
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := h.store.UpdatePassword(ctx, user.ID, string(hashedPassword)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+
+	// Log out every other device and hand this one a fresh session. Personal access
+	// tokens go too, as whoever knew the old password could have created them.
+	if err := h.store.RevokeUserSessions(ctx, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	if err := h.store.DeleteUserAccessTokens(ctx, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke access tokens"})
+		return
+	}
+	familyID, err := randomToken(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	response, err := h.issueTokens(ctx, user, familyID, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	response["message"] = "Password changed, other sessions and access tokens have been revoked"
+	c.JSON(http.StatusOK, response)
+}
+
+// POST /me/email
+func (h *Handler) ChangeEmail(c *gin.Context) {
+	var input struct {
+		Email       string `json:"email" binding:"required,email"`
+		Password    string `json:"password" binding:"required_without=ReauthToken"`
+		ReauthToken string `json:"reauth_token"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := h.authenticate(c, input.Password, input.ReauthToken)
+	if user == nil {
+		return
+	}
+
+	ctx := c.Request.Context()
+	_, err := h.store.GetUserByEmail(ctx, input.Email)
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email address is already in use"})
+		return
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	// The address only changes once the link sent to it is followed
+	secret, err := randomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+	now := time.Now()
+	err = h.store.CreateUserToken(ctx, &models.UserToken{
+		UserID:    user.ID,
+		Purpose:   models.TokenChangeEmail,
+		TokenHash: store.HashToken(secret),
+		Email:     input.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(verifyEmailTokenTTL),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+
+	err = h.opts.Mailer.Send(ctx, mailer.Message{
+		To:      input.Email,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Hi %s,\n\nTo use this address for your account, open this link:\n\n%s\n\nThe link expires in %d hours.\n",
+			user.Username, h.appLink("/confirm-email", secret), int(verifyEmailTokenTTL.Hours())),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send confirmation email"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Confirmation email sent to the new address"})
+}
+
+// POST /me/email/confirm
+func (h *Handler) ConfirmEmailChange(c *gin.Context) {
+	var input struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	token, err := h.store.ConsumeUserToken(ctx, models.TokenChangeEmail, store.HashToken(input.Token))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+		return
+	}
+
+	user, err := h.store.GetUserByID(ctx, token.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	oldEmail := user.Email
+
+	err = h.store.UpdateEmail(ctx, token.UserID, token.Email)
+	if errors.Is(err, store.ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email address is already in use"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+		return
+	}
+
+	// Let the previous address know, in case the change was not wanted
+	err = h.opts.Mailer.Send(ctx, mailer.Message{
+		To:      oldEmail,
+		Subject: "Your email address was changed",
+		Body:    fmt.Sprintf("Hi %s,\n\nThe email address of your account was changed to %s.\n", user.Username, token.Email),
+	})
+	if err != nil {
+		log.Printf("❌ Failed to notify user %d of the email change: %v", user.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email address changed", "email": token.Email})
+}
+
+// DELETE /me
+func (h *Handler) DeleteAccount(c *gin.Context) {
+	var input struct {
+		Password    string `json:"password" binding:"required_without=ReauthToken"`
+		ReauthToken string `json:"reauth_token"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := h.authenticate(c, input.Password, input.ReauthToken)
+	if user == nil {
+		return
+	}
+
+	// Revoke first: the revocation list outlives the user's rows
+	ctx := c.Request.Context()
+	if err := h.store.RevokeUserSessions(ctx, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	if err := h.store.DeleteUser(ctx, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deleted"})
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/streaks"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTimeZoneDecidesToday(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Read"})
+
+	// At any time of day, UTC+14 and UTC-11 are on different dates
+	for _, zone := range []string{"Pacific/Kiritimati", "Pacific/Pago_Pago"} {
+		var user models.User
+		expect(t, e.do("PATCH", "/me", token, gin.H{"time_zone": zone}), http.StatusOK, &user)
+		if user.TimeZone != zone {
+			t.Fatalf("time zone = %q, want %q", user.TimeZone, zone)
+		}
+
+		loc, _ := time.LoadLocation(zone)
+		var completed struct {
+			Date string `json:"date"`
+		}
+		expect(t, e.do("POST", habitPath(habit.ID, ""), token, nil), http.StatusOK, &completed)
+		if want := testNow.In(loc).Format("2006-01-02"); completed.Date != want {
+			t.Errorf("completed in %s on %s, want %s", zone, completed.Date, want)
+		}
+	}
+
+	expect(t, e.do("PATCH", "/me", token, gin.H{"time_zone": "Mars/Olympus"}), http.StatusBadRequest, nil)
+	// The server's zone is not the user's
+	expect(t, e.do("PATCH", "/me", token, gin.H{"time_zone": "Local"}), http.StatusBadRequest, nil)
+}
+
+func TestTimeZoneChangeRecomputesStreaks(t *testing.T) {
+	e := newTestEnv(t)
+	// Noon in UTC is already tomorrow in Kiritimati, UTC+14
+	noon := time.Date(testNow.Year(), testNow.Month(), testNow.Day(), 12, 0, 0, 0, time.UTC)
+	e.h.SetClock(streaks.FixedClock(noon))
+	_, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Read"})
+	e.complete(token, habit.ID, day(-1))
+
+	currentStreak := func() int {
+		var streaks []streakResponse
+		expect(t, e.do("GET", "/habits/streak", token, nil), http.StatusOK, &streaks)
+		return streaks[0].CurrentStreak
+	}
+	if got := currentStreak(); got != 1 {
+		t.Fatalf("current streak = %d, want 1 while yesterday was done", got)
+	}
+
+	expect(t, e.do("PATCH", "/me", token, gin.H{"time_zone": "Pacific/Kiritimati"}), http.StatusOK, nil)
+	if got := currentStreak(); got != 0 {
+		t.Errorf("current streak = %d, want 0 once yesterday is two days ago", got)
+	}
+}
+
+func TestUpdateUsername(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+
+	var user models.User
+	expect(t, e.do("PATCH", "/me", token, gin.H{"username": " annie "}), http.StatusOK, &user)
+	if user.Username != "annie" || user.TimeZone != models.DefaultTimeZone || user.Password != "" {
+		t.Errorf("user = %+v, want annie, still in UTC and without password", user)
+	}
+
+	expect(t, e.do("PATCH", "/me", token, gin.H{"username": "  "}), http.StatusBadRequest, nil)
+}
+
+func TestChangePassword(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+	other := e.login("ann@example.com")
+
+	w := e.do("POST", "/me/password", token, gin.H{"current_password": "not the password", "new_password": "a brand new password"})
+	expect(t, w, http.StatusForbidden, nil)
+
+	var changed loginResponse
+	w = e.do("POST", "/me/password", token, gin.H{"current_password": testPassword, "new_password": "a brand new password"})
+	expect(t, w, http.StatusOK, &changed)
+
+	// Every session is logged out, and this device gets a new one
+	expect(t, e.do("GET", "/me", other.Token, nil), http.StatusUnauthorized, nil)
+	expect(t, e.do("GET", "/me", token, nil), http.StatusUnauthorized, nil)
+	expect(t, e.do("GET", "/me", changed.Token, nil), http.StatusOK, nil)
+}
+
+func TestReauthenticationCountsTowardsLoginLockout(t *testing.T) {
+	e := newTestEnv(t)
+	_, token := e.signUp("ann@example.com")
+
+	wrong := gin.H{"current_password": "not the password", "new_password": "a brand new password"}
+	for i := 1; i < loginBackoff.Threshold; i++ {
+		expect(t, e.do("POST", "/me/password", token, wrong), http.StatusForbidden, nil)
+	}
+	expect(t, e.do("POST", "/me/password", token, wrong), http.StatusTooManyRequests, nil)
+
+	// Locked out everywhere the password is asked for, even when it is right
+	right := gin.H{"current_password": testPassword, "new_password": "a brand new password"}
+	expect(t, e.do("POST", "/me/password", token, right), http.StatusTooManyRequests, nil)
+	expect(t, e.do("POST", "/me/email", token, gin.H{"email": "annie@example.com", "password": testPassword}), http.StatusTooManyRequests, nil)
+	expect(t, e.do("DELETE", "/me", token, gin.H{"password": testPassword}), http.StatusTooManyRequests, nil)
+	expect(t, e.do("POST", "/login", "", gin.H{"email": "ann@example.com", "password": testPassword}), http.StatusTooManyRequests, nil)
+}
+
+func TestChangeEmail(t *testing.T) {
+	e := newTestEnv(t)
+	user, token := e.signUp("ann@example.com")
+	e.signUp("bob@example.com")
+
+	w := e.do("POST", "/me/email", token, gin.H{"email": "bob@example.com", "password": testPassword})
+	expect(t, w, http.StatusConflict, nil)
+	w = e.do("POST", "/me/email", token, gin.H{"email": "ann@new.example", "password": "not the password"})
+	expect(t, w, http.StatusForbidden, nil)
+
+	w = e.do("POST", "/me/email", token, gin.H{"email": "ann@new.example", "password": testPassword})
+	expect(t, w, http.StatusAccepted, nil)
+	if e.store.users[user.ID].Email != "ann@example.com" {
+		t.Fatal("the address changed before the link was followed")
+	}
+
+	confirm := gin.H{"token": e.mail.lastToken(t, "ann@new.example")}
+	expect(t, e.do("POST", "/me/email/confirm", "", confirm), http.StatusOK, nil)
+	if u := e.store.users[user.ID]; u.Email != "ann@new.example" || !u.EmailVerified {
+		t.Errorf("user = %+v, want the new address, verified", u)
+	}
+	if last := e.mail.sent[len(e.mail.sent)-1]; last.To != "ann@example.com" {
+		t.Errorf("last mail went to %s, want the old address to be told", last.To)
+	}
+	expect(t, e.do("POST", "/me/email/confirm", "", confirm), http.StatusBadRequest, nil)
+}
+
+func TestDeleteAccount(t *testing.T) {
+	e := newTestEnv(t)
+	user, token := e.signUp("ann@example.com")
+	habit := e.createHabit(token, gin.H{"title": "Read"})
+	e.complete(token, habit.ID, day(0))
+
+	expect(t, e.do("DELETE", "/me", token, gin.H{"password": "not the password"}), http.StatusForbidden, nil)
+	expect(t, e.do("DELETE", "/me", token, gin.H{"password": testPassword}), http.StatusOK, nil)
+
+	if _, ok := e.store.users[user.ID]; ok {
+		t.Error("the user was not deleted")
+	}
+	if len(e.store.habits) != 0 || len(e.store.completions) != 0 || len(e.store.streaks) != 0 {
+		t.Error("the user's habit data was not deleted")
+	}
+	expect(t, e.do("GET", "/me", token, nil), http.StatusUnauthorized, nil)
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"habit-tracker/backend/mailer"
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/store"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	verifyEmailTokenTTL   = 48 * time.Hour
+	resetPasswordTokenTTL = time.Hour
+)
+
+// createUserToken stores a new single-use token for user and returns its secret value
+func (h *Handler) createUserToken(ctx context.Context, userID int, purpose string, ttl time.Duration) (string, error) {
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	err = h.store.CreateUserToken(ctx, &models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: store.HashToken(secret),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+	return secret, err
+}
+
+// appLink builds a frontend URL carrying a token
+func (h *Handler) appLink(path, token string) string {
+	return strings.TrimRight(h.opts.AppURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+func (h *Handler) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	token, err := h.createUserToken(ctx, user.ID, models.TokenVerifyEmail, verifyEmailTokenTTL)
+	if err != nil {
+		return err
+	}
+	return h.opts.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening this link:\n\n%s\n\nThe link expires in %d hours.\n",
+			user.Username, h.appLink("/verify-email", token), int(verifyEmailTokenTTL.Hours())),
+	})
+}
+
+// POST /verify-email
+func (h *Handler) VerifyEmail(c *gin.Context) {
+	var input struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := h.store.ConsumeUserToken(c.Request.Context(), models.TokenVerifyEmail, store.HashToken(input.Token))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	if err := h.store.SetEmailVerified(c.Request.Context(), token.UserID, true); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// POST /verify-email/resend
+func (h *Handler) ResendVerification(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	user, err := h.store.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	if user.EmailVerified {
+		c.JSON(http.StatusOK, gin.H{"message": "Email is already verified"})
+		return
+	}
+
+	if err := h.sendVerificationEmail(c.Request.Context(), user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// POST /password/forgot
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Answer the same whether or not the account exists, so emails cannot be probed
+	response := gin.H{"message": "If the account exists, a password reset email has been sent"}
+
+	ctx := c.Request.Context()
+	user, err := h.store.GetUserByEmail(ctx, input.Email)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	token, err := h.createUserToken(ctx, user.ID, models.TokenResetPassword, resetPasswordTokenTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reset token"})
+		return
+	}
+	err = h.opts.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. If it was you, open this link:\n\n%s\n\nThe link expires in %d minutes. If you did not ask for it, you can ignore this email.\n",
+			user.Username, h.appLink("/reset-password", token), int(resetPasswordTokenTTL.Minutes())),
+	})
+	if err != nil {
+		log.Printf("❌ Failed to send password reset email to user %d: %v", user.ID, err)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// POST /password/reset
+func (h *Handler) ResetPassword(c *gin.Context) {
+	var input struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required,min=8"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	token, err := h.store.ConsumeUserToken(ctx, models.TokenResetPassword, store.HashToken(input.Token))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+	if err := h.store.UpdatePassword(ctx, token.UserID, string(hashedPassword)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	// Whoever knew the old password must not stay logged in. Receiving the mail also proves the address.
+	if err := h.store.RevokeUserSessions(ctx, token.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	if err := h.store.DeleteUserAccessTokens(ctx, token.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke access tokens"})
+		return
+	}
+	if err := h.store.SetEmailVerified(ctx, token.UserID, true); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset, please log in again"})
+}
@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"habit-tracker/backend/models"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestVerifyEmail(t *testing.T) {
+	e := newTestEnv(t)
+
+	var user models.User
+	w := e.do("POST", "/users", "", gin.H{"username": "ann", "email": "ann@example.com", "password": testPassword})
+	expect(t, w, http.StatusCreated, &user)
+	token := e.mail.lastToken(t, "ann@example.com")
+
+	e.h.opts.RequireEmailVerification = true
+	login := gin.H{"email": "ann@example.com", "password": testPassword}
+	expect(t, e.do("POST", "/login", "", login), http.StatusForbidden, nil)
+
+	expect(t, e.do("POST", "/verify-email", "", gin.H{"token": token}), http.StatusOK, nil)
+	if !e.store.users[user.ID].EmailVerified {
+		t.Error("email is not verified")
+	}
+	expect(t, e.do("POST", "/login", "", login), http.StatusOK, nil)
+
+	// The link only works once
+	expect(t, e.do("POST", "/verify-email", "", gin.H{"token": token}), http.StatusBadRequest, nil)
+}
+
+func TestPasswordReset(t *testing.T) {
+	e := newTestEnv(t)
+	_, session := e.signUp("ann@example.com")
+
+	// Unknown addresses get the same answer and no mail
+	expect(t, e.do("POST", "/password/forgot", "", gin.H{"email": "nobody@example.com"}), http.StatusOK, nil)
+	if len(e.mail.sent) != 0 {
+		t.Fatalf("sent %d mails for an unknown address", len(e.mail.sent))
+	}
+
+	expect(t, e.do("POST", "/tokens", session, gin.H{"name": "cli", "scopes": []string{models.ScopeRead}}), http.StatusCreated, nil)
+	expect(t, e.do("POST", "/password/forgot", "", gin.H{"email": "ann@example.com"}), http.StatusOK, nil)
+	token := e.mail.lastToken(t, "ann@example.com")
+
+	const newPassword = "a brand new password"
+	expect(t, e.do("POST", "/password/reset", "", gin.H{"token": token, "password": newPassword}), http.StatusOK, nil)
+	expect(t, e.do("GET", "/me", session, nil), http.StatusUnauthorized, nil)
+	if len(e.store.accessTokens) != 0 {
+		t.Errorf("%d access tokens survived the reset", len(e.store.accessTokens))
+	}
+
+	expect(t, e.do("POST", "/login", "", gin.H{"email": "ann@example.com", "password": testPassword}), http.StatusUnauthorized, nil)
+	expect(t, e.do("POST", "/login", "", gin.H{"email": "ann@example.com", "password": newPassword}), http.StatusOK, nil)
+}
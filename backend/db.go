@@ -3,25 +3,38 @@ package main
 import (
 	"database/sql"
 	"fmt"
+	"habit-tracker/backend/migrations"
+	"habit-tracker/backend/store"
 	"log"
-
-	_ "github.com/lib/pq"
 )
 
-func InitDB() (*sql.DB, error) {
-	connStr := "host=localhost port=5432 user=habituser password=Lionheart@123 dbname=habitdb sslmode=disable"
-	db, err := sql.Open("postgres", connStr)
+// InitDB connects to the database and, if autoMigrate is set, applies any pending migrations
+func InitDB(connStr string, autoMigrate bool) (*sql.DB, store.Dialect, error) {
+	db, dialect, err := store.Open(connStr)
 	if err != nil {
 		log.Fatalf("❌ Error opening database: %v", err)
-		return nil, err
+		return nil, "", err
 	}
 
 	err = db.Ping()
 	if err != nil {
 		log.Fatalf("❌ Error connecting to the database: %v", err)
-		return nil, err
+		return nil, "", err
+	}
+
+	fmt.Printf("✅ Successfully connected to %s database!\n", dialect)
+
+	if autoMigrate {
+		runner, err := migrations.NewRunner(db, dialect)
+		if err != nil {
+			return nil, "", err
+		}
+		applied, err := runner.Up()
+		if err != nil {
+			return nil, "", err
+		}
+		fmt.Printf("✅ Applied %d pending migration(s)\n", applied)
 	}
 
-	fmt.Println("✅ Successfully connected to PostgreSQL database!")
-	return db, nil
+	return db, dialect, nil
 }
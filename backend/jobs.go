@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"habit-tracker/backend/controllers"
+	"log"
+	"time"
+)
+
+// runStreakJob recomputes stale streaks every interval until ctx is cancelled.
+// Checking often is cheap: each user is only processed once per local day.
+func runStreakJob(ctx context.Context, h *controllers.Handler, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := h.RecomputeStreaks(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("❌ Streak recompute failed: %v", err)
+		} else if n > 0 {
+			log.Printf("🔁 Recomputed streaks for %d users", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
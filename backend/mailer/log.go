@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// LogMailer is meant for local development: it writes each message to a file
+// in dir, or prints it to the log when dir is empty
+type LogMailer struct {
+	dir  string
+	from string
+}
+
+// NewLog returns a LogMailer writing to dir
+func NewLog(dir, from string) *LogMailer {
+	return &LogMailer{dir: dir, from: from}
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9@._-]+`)
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	data := format(m.from, msg)
+	if m.dir == "" {
+		log.Printf("📧 Mail to %s:\n%s", msg.To, data)
+		return nil
+	}
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405.000000000"), unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	path := filepath.Join(m.dir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	log.Printf("📧 Mail to %s written to %s", msg.To, path)
+	return nil
+}
@@ -0,0 +1,45 @@
+// Package mailer sends the emails the backend needs, such as verification links.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns an SMTP mailer when smtpURL is set, and otherwise a mailer that
+// writes messages to dir, or to the log if dir is empty as well
+func New(smtpURL, dir, from string) (Mailer, error) {
+	if smtpURL != "" {
+		return NewSMTP(smtpURL, from)
+	}
+	return NewLog(dir, from), nil
+}
+
+// format renders msg as an RFC 5322 message
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -1,19 +1,29 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"habit-tracker/backend/authtoken"
+	"habit-tracker/backend/config"
 	"habit-tracker/backend/controllers"
+	"habit-tracker/backend/mailer"
+	"habit-tracker/backend/oidc"
+	"habit-tracker/backend/ratelimit"
+	"habit-tracker/backend/store"
 	"log"
-	
+	"os"
+	_ "time/tzdata" // embed the IANA database so user time zones resolve on minimal hosts
+
 	"github.com/gin-gonic/gin"
 )
 
 // CORS middleware
-func CORSMiddleware() gin.HandlerFunc {
+func CORSMiddleware(origin string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
+		c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -25,36 +35,150 @@ func CORSMiddleware() gin.HandlerFunc {
 }
 
 func main() {
-	db, err := InitDB()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		case "config":
+			runConfig(os.Args[2:])
+			return
+		}
+	}
+
+	cfg := loadConfig(flag.CommandLine, os.Args[1:])
+	if cfg.IsProduction() {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	db, dialect, err := InitDB(cfg.DatabaseURL, cfg.AutoMigrate)
 	if err != nil {
 		log.Fatalf("❌ Error connecting to the database: %v", err)
 	}
 
-	// Pass db and jwtSecret to controllers
-	controllers.SetDB(db)
-	controllers.SetJWTSecret(GetJWTSecret())
+	// Pass the store and jwtSecret to controllers
+	mail, err := mailer.New(cfg.SMTPURL, cfg.MailDir, cfg.MailFrom)
+	if err != nil {
+		log.Fatalf("❌ Error configuring mail: %v", err)
+	}
+
+	// Access tokens are signed with the configured keys, or the JWT secret without any
+	keys := authtoken.NewHMAC([]byte(cfg.JWTSecret))
+	if len(cfg.JWTKeys) > 0 {
+		keys, err = authtoken.LoadKeys(cfg.JWTKeys)
+		if err != nil {
+			log.Fatalf("❌ Error loading JWT keys: %v", err)
+		}
+		log.Printf("🔑 Signing access tokens with %s key %s", keys.Algorithm(), keys.KeyID())
+	}
+	internalSecret, err := keys.InternalSecret()
+	if err != nil {
+		log.Fatalf("❌ Error deriving the internal token secret: %v", err)
+	}
+
+	var sso *oidc.Provider
+	if cfg.OIDCIssuer != "" {
+		sso = oidc.New(oidc.Config{
+			Issuer:       cfg.OIDCIssuer,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+		})
+	}
+
+	var limiter ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimitStore == config.RateLimitDatabase {
+		limiter = ratelimit.NewSQLStore(db, dialect)
+	}
+
+	st := store.New(db, dialect)
+	h := controllers.NewHandler(st, internalSecret, controllers.Options{
+		Mailer:                   mail,
+		AppURL:                   cfg.AppURL,
+		RequireEmailVerification: cfg.RequireEmailVerification,
+		Limiter:                  limiter,
+		OIDC:                     sso,
+		Keys:                     keys,
+	})
 
 	r := gin.Default()
-	
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("❌ Invalid trusted proxies: %v", err)
+	}
+
 	// Add CORS middleware
-	r.Use(CORSMiddleware())
+	r.Use(CORSMiddleware(cfg.CORSOrigin))
+
+	// Every client gets a generous overall budget, the sensitive routes below a tighter one
+	r.Use(ratelimit.Middleware(limiter, "ip", ratelimit.PerMinute(300), ratelimit.ByIP))
+	loginLimit := ratelimit.Middleware(limiter, "login-ip", ratelimit.PerMinute(20), ratelimit.ByIP)
+	registerLimit := ratelimit.Middleware(limiter, "register-ip", ratelimit.PerHour(20), ratelimit.ByIP)
+	mailLimit := ratelimit.Middleware(limiter, "mail-ip", ratelimit.PerHour(10), ratelimit.ByIP)
+
+	// Let streaks of habits nobody completes decay after each user's midnight
+	if interval := cfg.StreakJobInterval.Duration; interval > 0 {
+		go runStreakJob(context.Background(), h, interval)
+	}
+
+	auth := AuthMiddleware(keys, st)
 
 	// These are public routes
-	r.POST("/users", controllers.RegisterUser)
-	r.POST("/login", controllers.LoginUser)
-	
-	// These are protected by JWT middleware
-	r.GET("/habits", AuthMiddleware(), controllers.GetHabits)
-	r.POST("/habits", AuthMiddleware(), controllers.CreateHabit)
-	r.PUT("/habits/:id", AuthMiddleware(), controllers.UpdateHabit)
-	r.DELETE("/habits/:id", AuthMiddleware(), controllers.DeleteHabit)
-	r.POST("/habits/:id", AuthMiddleware(), controllers.CompleteHabit)
-	r.GET("/habits/completed", AuthMiddleware(), controllers.GetCompletedHabits)
-	r.GET("/habits/streak", AuthMiddleware(), controllers.GetHabitsStreaks)
-	r.GET("/habits/:id/history", AuthMiddleware(), controllers.GetHabitHistory)
-	r.GET("/habits/:id/analytics", AuthMiddleware(), controllers.GetHabitAnalytics)
-	r.GET("/habits/summary", AuthMiddleware(), controllers.GetHabitSummary)
-
-	log.Println("🚀 Server starting on http://localhost:8080")
-	r.Run() // Default port :8080
-}
\ No newline at end of file
+	r.GET("/.well-known/jwks.json", h.JWKS)
+	r.POST("/users", registerLimit, h.RegisterUser)
+	r.POST("/login", loginLimit, h.LoginUser)
+	r.POST("/login/mfa", loginLimit, h.VerifyMFA)
+	if sso != nil {
+		r.GET("/auth/oidc/login", loginLimit, h.OIDCLogin)
+		r.GET("/auth/oidc/callback", loginLimit, h.OIDCCallback)
+		r.POST("/auth/oidc/token", loginLimit, h.OIDCToken)
+	}
+	r.POST("/token/refresh", loginLimit, h.RefreshToken)
+	r.POST("/verify-email", h.VerifyEmail)
+	r.POST("/password/forgot", mailLimit, h.ForgotPassword)
+	r.POST("/password/reset", loginLimit, h.ResetPassword)
+	r.POST("/me/email/confirm", h.ConfirmEmailChange)
+
+	// These are protected by JWT middleware and limited per user
+	api := r.Group("/", auth, ratelimit.Middleware(limiter, "user", ratelimit.PerMinute(120), ratelimit.ByUser))
+	api.POST("/logout", h.Logout)
+	api.POST("/logout-all", h.LogoutAll)
+	api.POST("/verify-email/resend", mailLimit, h.ResendVerification)
+	api.GET("/habits", h.GetHabits)
+	api.POST("/habits", h.CreateHabit)
+	api.PUT("/habits/:id", h.UpdateHabit)
+	api.DELETE("/habits/:id", h.DeleteHabit)
+	api.POST("/habits/:id", h.CompleteHabit)
+	api.PUT("/habits/:id/completions/:date", h.UpdateCompletion)
+	api.DELETE("/habits/:id/completions/:date", h.DeleteCompletion)
+	api.POST("/habits/backfill", h.BackfillCompletions)
+	api.GET("/habits/completed", h.GetCompletedHabits)
+	api.GET("/habits/streak", h.GetHabitsStreaks)
+	api.GET("/habits/:id/history", h.GetHabitHistory)
+	api.GET("/habits/:id/analytics", h.GetHabitAnalytics)
+	api.GET("/habits/:id/strength", h.GetHabitStrength)
+	api.GET("/habits/:id/calendar", h.GetHabitCalendar)
+	api.GET("/habits/calendar", h.GetCalendar)
+	api.GET("/habits/:id/stats", h.GetHabitStats)
+	api.GET("/habits/stats", h.GetStats)
+	api.GET("/habits/:id/patterns", h.GetHabitPatterns)
+	api.GET("/habits/patterns", h.GetPatterns)
+	api.GET("/habits/correlations", h.GetCorrelations)
+	api.GET("/habits/summary", h.GetHabitSummary)
+	api.GET("/me", h.GetProfile)
+	api.PATCH("/me", h.UpdateProfile)
+	api.DELETE("/me", h.DeleteAccount)
+	api.POST("/me/password", h.ChangePassword)
+	api.POST("/me/email", h.ChangeEmail)
+	if sso != nil {
+		api.POST("/me/reauth/oidc", loginLimit, h.ReauthOIDC)
+	}
+	api.POST("/me/2fa/enroll", h.EnrollTwoFactor)
+	api.POST("/me/2fa/confirm", h.ConfirmTwoFactor)
+	api.POST("/me/2fa/disable", h.DisableTwoFactor)
+	api.GET("/tokens", h.ListAccessTokens)
+	api.POST("/tokens", h.CreateAccessToken)
+	api.DELETE("/tokens/:id", h.DeleteAccessToken)
+
+	log.Printf("🚀 Server starting on http://localhost%s", cfg.Addr())
+	r.Run(cfg.Addr())
+}
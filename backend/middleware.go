@@ -1,14 +1,30 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"habit-tracker/backend/authtoken"
+	"habit-tracker/backend/controllers"
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/store"
+	"log"
 	"net/http"
-	
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// authStore is what AuthMiddleware needs to check credentials
+type authStore interface {
+	store.SessionStore
+	store.AccessTokenStore
+}
+
+// AuthMiddleware rejects requests without a valid JWT signed with one of keys,
+// including tokens revoked by logging out, or a personal access token with
+// the scope the route needs
+func AuthMiddleware(keys *authtoken.Keys, tokens authStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get the Authorization header
 		authHeader := c.GetHeader("Authorization")
@@ -28,28 +44,110 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Parse and validate the token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Validate signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return jwtSecret, nil
-		})
+		if strings.HasPrefix(tokenString, controllers.AccessTokenPrefix) {
+			accessTokenAuth(c, tokens, tokenString)
+			return
+		}
 
-		if err != nil || !token.Valid {
+		// Parse and validate the token; this also refuses the tokens of half-finished
+		// two-factor logins, which are only good for POST /login/mfa
+		claims, err := keys.Verify(tokenString)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
-		// Optionally, you can extract claims and set user info in context
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			c.Set("user_id", claims["user_id"])
-			c.Set("email", claims["email"])
+		// Reject tokens whose session was logged out
+		revoked, err := tokens.IsTokenRevoked(c.Request.Context(), claims.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check token"})
+			c.Abort()
+			return
 		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", claims.UserID)
+		c.Set("email", claims.Email)
+		c.Set("session_id", claims.SessionID)
 
 		// Proceed to the next handler
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// accessTokenAuth authenticates a request carrying a personal access token
+func accessTokenAuth(c *gin.Context, tokens store.AccessTokenStore, raw string) {
+	token, err := tokens.GetAccessTokenByHash(c.Request.Context(), store.HashToken(raw))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		c.Abort()
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check token"})
+		c.Abort()
+		return
+	}
+
+	now := time.Now()
+	if token.Expired(now) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		c.Abort()
+		return
+	}
+
+	scope := requiredScope(c)
+	if scope == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Personal access tokens cannot be used for this endpoint"})
+		c.Abort()
+		return
+	}
+	if !token.Allows(scope) {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Token is missing the %q scope", scope)})
+		c.Abort()
+		return
+	}
+
+	// Tokens can be used many times a second, a minute's precision is plenty
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > time.Minute {
+		if err := tokens.TouchAccessToken(c.Request.Context(), token.ID, now); err != nil {
+			log.Printf("⚠️  Failed to record use of access token %d: %v", token.ID, err)
+		}
+	}
+
+	c.Set("user_id", token.UserID)
+	c.Set("access_token_id", token.ID)
+	c.Next()
+}
+
+// completeRoutes are the routes open to tokens with the complete scope
+var completeRoutes = map[string]bool{
+	"POST /habits/:id":                     true,
+	"PUT /habits/:id/completions/:date":    true,
+	"DELETE /habits/:id/completions/:date": true,
+	"POST /habits/backfill":                true,
+}
+
+// requiredScope returns the scope a personal access token needs for the matched
+// route, or "" for account and session management, which needs a real login
+func requiredScope(c *gin.Context) string {
+	path := c.FullPath()
+	switch {
+	case path == "/me" && c.Request.Method == http.MethodGet:
+		return models.ScopeRead
+	case strings.HasPrefix(path, "/me"), strings.HasPrefix(path, "/tokens"),
+		strings.HasPrefix(path, "/logout"), strings.HasPrefix(path, "/verify-email"):
+		return ""
+	case completeRoutes[c.Request.Method+" "+path]:
+		return models.ScopeComplete
+	case c.Request.Method == http.MethodGet:
+		return models.ScopeRead
+	default:
+		return models.ScopeWrite
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"habit-tracker/backend/migrations"
+	"log"
+)
+
+// runMigrate implements the "migrate up|down|status" subcommand
+func runMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	steps := fs.Int("steps", 1, "number of migrations to roll back with \"down\"")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: backend migrate [flags] up|down|status")
+		fs.PrintDefaults()
+	}
+	cfg := loadConfig(fs, args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		log.Fatal("❌ Expected exactly one of up, down or status")
+	}
+
+	db, dialect, err := InitDB(cfg.DatabaseURL, false)
+	if err != nil {
+		log.Fatalf("❌ Error connecting to the database: %v", err)
+	}
+	defer db.Close()
+
+	runner, err := migrations.NewRunner(db, dialect)
+	if err != nil {
+		log.Fatalf("❌ Error loading migrations: %v", err)
+	}
+
+	switch fs.Arg(0) {
+	case "up":
+		applied, err := runner.Up()
+		if err != nil {
+			log.Fatalf("❌ Migration failed after %d applied: %v", applied, err)
+		}
+		fmt.Printf("✅ Applied %d migration(s)\n", applied)
+	case "down":
+		rolledBack, err := runner.Down(*steps)
+		if err != nil {
+			log.Fatalf("❌ Rollback failed after %d rolled back: %v", rolledBack, err)
+		}
+		fmt.Printf("✅ Rolled back %d migration(s)\n", rolledBack)
+	case "status":
+		statuses, err := runner.Status()
+		if err != nil {
+			log.Fatalf("❌ Error reading migration status: %v", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-30s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		fs.Usage()
+		log.Fatalf("❌ Unknown migrate command %q", fs.Arg(0))
+	}
+}
@@ -0,0 +1,226 @@
+// Package migrations applies the embedded, versioned database schema.
+//
+// Migration files live in sql/<dialect>/ and are named <version>_<name>.up.sql
+// and <version>_<name>.down.sql. Every dialect carries the same versions.
+// Applied versions are recorded in the schema_migrations table.
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"habit-tracker/backend/store"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql
+var files embed.FS
+
+// Migration is a single versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Runner applies and rolls back migrations against a database
+type Runner struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewRunner loads the embedded migrations for the given database dialect
+func NewRunner(db *sql.DB, dialect store.Dialect) (*Runner, error) {
+	migrations, err := load(files, path.Join("sql", string(dialect)))
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{db: db, migrations: migrations}, nil
+}
+
+// Up applies all pending migrations in order and returns how many were applied
+func (r *Runner) Up() (int, error) {
+	applied, err := r.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range r.migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		err := r.inTx(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(m.Up); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES ($1, $2)`, m.Version, time.Now())
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("migration %d_%s up: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Down rolls back the most recently applied migrations, at most steps of them
+func (r *Runner) Down(steps int) (int, error) {
+	applied, err := r.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(r.migrations) - 1; i >= 0 && count < steps; i-- {
+		m := r.migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		err := r.inTx(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(m.Down); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("migration %d_%s down: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Status lists every known migration and whether it has been applied
+func (r *Runner) Status() ([]MigrationStatus, error) {
+	applied, err := r.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(r.migrations))
+	for _, m := range r.migrations {
+		appliedAt, ok := applied[m.Version]
+		statuses = append(statuses, MigrationStatus{Migration: m, Applied: ok, AppliedAt: appliedAt})
+	}
+	return statuses, nil
+}
+
+// applied creates the version table if needed and returns the applied versions
+func (r *Runner) applied() (map[int]time.Time, error) {
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    BIGINT PRIMARY KEY,
+			applied_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	rows, err := r.db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+func (r *Runner) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// load reads and pairs the up/down files in dir, sorted by version
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		versionStr, label, found := strings.Cut(base, "_")
+		if !found {
+			return nil, fmt.Errorf("migration %q: expected <version>_<name>", name)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("migration %q: invalid version: %w", name, err)
+		}
+
+		body, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		} else if m.Name != label {
+			return nil, fmt.Errorf("migration version %d used by both %q and %q", version, m.Name, label)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s is missing its up or down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
@@ -0,0 +1,4 @@
+ALTER TABLE habits DROP COLUMN schedule_interval;
+ALTER TABLE habits DROP COLUMN schedule_times;
+ALTER TABLE habits DROP COLUMN schedule_weekdays;
+ALTER TABLE habits DROP COLUMN schedule_type;
@@ -0,0 +1,6 @@
+ALTER TABLE habit_completions DROP COLUMN value;
+
+ALTER TABLE habits DROP COLUMN comparison;
+ALTER TABLE habits DROP COLUMN unit;
+ALTER TABLE habits DROP COLUMN target;
+ALTER TABLE habits DROP COLUMN habit_type;
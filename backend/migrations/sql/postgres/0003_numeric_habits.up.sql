@@ -0,0 +1,6 @@
+ALTER TABLE habits ADD COLUMN habit_type TEXT NOT NULL DEFAULT 'boolean';
+ALTER TABLE habits ADD COLUMN target DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE habits ADD COLUMN unit TEXT NOT NULL DEFAULT '';
+ALTER TABLE habits ADD COLUMN comparison TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE habit_completions ADD COLUMN value DOUBLE PRECISION NOT NULL DEFAULT 1;
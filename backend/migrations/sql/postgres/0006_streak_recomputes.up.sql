@@ -0,0 +1,8 @@
+-- One row per user and local day whose streaks the nightly job has recomputed.
+-- Inserting the row is how a replica claims the work.
+CREATE TABLE IF NOT EXISTS streak_recomputes (
+    user_id    INTEGER     NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    day        DATE        NOT NULL,
+    claimed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, day)
+);
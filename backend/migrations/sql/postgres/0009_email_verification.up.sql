@@ -0,0 +1,14 @@
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT false;
+
+-- Single-use tokens mailed to users, e.g. to verify an email or reset a password
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose    TEXT        NOT NULL,
+    token_hash TEXT        NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS user_tokens_user_id_idx ON user_tokens (user_id);
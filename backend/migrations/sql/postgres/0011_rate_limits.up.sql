@@ -0,0 +1,14 @@
+-- Shared rate limit state, used when rate_limit_store is "database".
+-- Times are Unix microseconds.
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    id         TEXT             PRIMARY KEY,
+    tokens     DOUBLE PRECISION NOT NULL,
+    updated_at BIGINT           NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS rate_limit_failures (
+    id           TEXT    PRIMARY KEY,
+    failures     INTEGER NOT NULL,
+    locked_until BIGINT  NOT NULL DEFAULT 0,
+    updated_at   BIGINT  NOT NULL
+);
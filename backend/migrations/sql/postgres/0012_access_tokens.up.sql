@@ -0,0 +1,15 @@
+-- Personal access tokens for scripts. Only a hash of the token is kept;
+-- scopes is a comma-separated list.
+CREATE TABLE IF NOT EXISTS access_tokens (
+    id           SERIAL PRIMARY KEY,
+    user_id      INTEGER     NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name         TEXT        NOT NULL,
+    scopes       TEXT        NOT NULL,
+    token_hash   TEXT        NOT NULL UNIQUE,
+    prefix       TEXT        NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at TIMESTAMPTZ,
+    expires_at   TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS access_tokens_user_id_idx ON access_tokens (user_id);
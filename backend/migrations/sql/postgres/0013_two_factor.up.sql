@@ -0,0 +1,19 @@
+-- TOTP enrolment of a user. The row exists but is not enabled until the user
+-- has proven their authenticator works. last_step stops a code being used twice.
+CREATE TABLE IF NOT EXISTS two_factor (
+    user_id    INTEGER     PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret     TEXT        NOT NULL,
+    enabled    BOOLEAN     NOT NULL DEFAULT false,
+    last_step  BIGINT      NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- One-time codes for when the authenticator is lost, stored hashed
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id        SERIAL PRIMARY KEY,
+    user_id   INTEGER     NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash TEXT        NOT NULL,
+    used_at   TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS recovery_codes_user_id_idx ON recovery_codes (user_id);
@@ -0,0 +1,12 @@
+-- Accounts at an OpenID Connect provider that can sign in as a user.
+-- subject is the provider's stable id for the account, unlike the email address.
+CREATE TABLE IF NOT EXISTS user_identities (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    issuer     TEXT        NOT NULL,
+    subject    TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (issuer, subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identities_user_id_idx ON user_identities (user_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS habit_strength;
+
+ALTER TABLE habit_streaks DROP COLUMN strength;
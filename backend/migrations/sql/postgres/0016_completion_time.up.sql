@@ -0,0 +1,3 @@
+-- When the completion was checked in, NULL for rows imported or recorded before this existed.
+-- For numeric habits it is the latest entry of the day.
+ALTER TABLE habit_completions ADD COLUMN completed_at TIMESTAMPTZ;
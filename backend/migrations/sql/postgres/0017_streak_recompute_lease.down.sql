@@ -0,0 +1 @@
+ALTER TABLE streak_recomputes DROP COLUMN leased_until;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS habit_streaks;
+DROP TABLE IF EXISTS habit_completions;
+DROP TABLE IF EXISTS habits;
+DROP TABLE IF EXISTS users;
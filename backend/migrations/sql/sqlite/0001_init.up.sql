@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS users (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    username   TEXT      NOT NULL,
+    email      TEXT      NOT NULL UNIQUE,
+    password   TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS habits (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id     INTEGER   NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title       TEXT      NOT NULL,
+    description TEXT      NOT NULL DEFAULT '',
+    created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS habits_user_id_idx ON habits (user_id);
+
+CREATE TABLE IF NOT EXISTS habit_completions (
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    habit_id       INTEGER NOT NULL REFERENCES habits(id) ON DELETE CASCADE,
+    user_id        INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    date_completed DATE    NOT NULL,
+    UNIQUE (habit_id, date_completed)
+);
+
+CREATE INDEX IF NOT EXISTS habit_completions_user_id_idx ON habit_completions (user_id);
+
+CREATE TABLE IF NOT EXISTS habit_streaks (
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    habit_id       INTEGER NOT NULL REFERENCES habits(id) ON DELETE CASCADE,
+    user_id        INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    current_streak INTEGER NOT NULL DEFAULT 0,
+    longest_streak INTEGER NOT NULL DEFAULT 0,
+    last_completed DATE,
+    UNIQUE (habit_id, user_id)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE habits ADD COLUMN schedule_type TEXT NOT NULL DEFAULT 'daily';
+ALTER TABLE habits ADD COLUMN schedule_weekdays INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE habits ADD COLUMN schedule_times INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE habits ADD COLUMN schedule_interval INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE habits DROP COLUMN kind;
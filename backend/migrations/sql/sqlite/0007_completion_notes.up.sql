@@ -0,0 +1 @@
+ALTER TABLE habit_completions ADD COLUMN note TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,22 @@
+-- Each row is one refresh token. Rotating a token revokes its row and adds a new
+-- one to the same family; presenting a revoked token revokes the whole family.
+CREATE TABLE IF NOT EXISTS sessions (
+    id                INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id           INTEGER   NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id         TEXT      NOT NULL,
+    token_hash        TEXT      NOT NULL UNIQUE,
+    access_jti        TEXT      NOT NULL,
+    access_expires_at TIMESTAMP NOT NULL,
+    created_at        TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at        TIMESTAMP NOT NULL,
+    revoked_at        TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id);
+CREATE INDEX IF NOT EXISTS sessions_family_id_idx ON sessions (family_id);
+
+-- Access tokens revoked before they expire
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti        TEXT      PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
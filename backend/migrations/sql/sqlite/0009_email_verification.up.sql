@@ -0,0 +1,14 @@
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT false;
+
+-- Single-use tokens mailed to users, e.g. to verify an email or reset a password
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER   NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose    TEXT      NOT NULL,
+    token_hash TEXT      NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    used_at    TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS user_tokens_user_id_idx ON user_tokens (user_id);
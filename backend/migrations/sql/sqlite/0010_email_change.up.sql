@@ -0,0 +1,2 @@
+-- The new address of an email change, empty for other tokens
+ALTER TABLE user_tokens ADD COLUMN email TEXT NOT NULL DEFAULT '';
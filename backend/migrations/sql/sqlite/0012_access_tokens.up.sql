@@ -0,0 +1,15 @@
+-- Personal access tokens for scripts. Only a hash of the token is kept;
+-- scopes is a comma-separated list.
+CREATE TABLE IF NOT EXISTS access_tokens (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id      INTEGER   NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name         TEXT      NOT NULL,
+    scopes       TEXT      NOT NULL,
+    token_hash   TEXT      NOT NULL UNIQUE,
+    prefix       TEXT      NOT NULL,
+    created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    expires_at   TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS access_tokens_user_id_idx ON access_tokens (user_id);
@@ -0,0 +1,10 @@
+ALTER TABLE habit_streaks ADD COLUMN strength REAL NOT NULL DEFAULT 0;
+
+-- A habit's strength score at the end of each day, for charting
+CREATE TABLE IF NOT EXISTS habit_strength (
+    habit_id INTEGER NOT NULL REFERENCES habits(id) ON DELETE CASCADE,
+    user_id  INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    day      DATE    NOT NULL,
+    strength REAL    NOT NULL,
+    PRIMARY KEY (habit_id, day)
+);
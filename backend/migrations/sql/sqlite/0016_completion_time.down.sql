@@ -0,0 +1 @@
+ALTER TABLE habit_completions DROP COLUMN completed_at;
@@ -0,0 +1,3 @@
+-- Set while a replica recomputes the user's streaks for the day, NULL once it finished.
+-- A lease that ran out was held by a replica that died mid-run and may be claimed again.
+ALTER TABLE streak_recomputes ADD COLUMN leased_until TIMESTAMP;
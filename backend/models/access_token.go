@@ -0,0 +1,51 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Scopes a personal access token can be granted
+const (
+	ScopeRead     = "read"     // read habits, streaks and analytics
+	ScopeComplete = "complete" // record, edit and backfill completions
+	ScopeWrite    = "write"    // everything above plus creating, editing and deleting habits
+)
+
+// AccessToken is a personal access token for scripts and integrations.
+// Only a hash of the secret is stored; Prefix is kept so users can tell tokens apart.
+type AccessToken struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"-"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	TokenHash  string     `json:"-"`
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// ValidateScopes rejects an empty or unknown list of scopes
+func ValidateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+	for _, s := range scopes {
+		if s != ScopeRead && s != ScopeComplete && s != ScopeWrite {
+			return fmt.Errorf("unknown scope %q, use %q, %q or %q", s, ScopeRead, ScopeComplete, ScopeWrite)
+		}
+	}
+	return nil
+}
+
+// Allows reports whether the token grants scope; write implies the others
+func (t *AccessToken) Allows(scope string) bool {
+	return slices.Contains(t.Scopes, scope) || slices.Contains(t.Scopes, ScopeWrite)
+}
+
+// Expired reports whether the token has expired at now
+func (t *AccessToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && now.After(*t.ExpiresAt)
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Completion records that a habit was done on a given day
+type Completion struct {
+	ID            int       `json:"id"`
+	HabitID       int       `json:"habit_id"`
+	UserID        int       `json:"user_id"`
+	DateCompleted time.Time `json:"date_completed"`
+}
+
+// CompletedHabit is a completion joined with the habit it belongs to
+type CompletedHabit struct {
+	Completion
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// DailyValue is the recorded total of a habit on one day. Boolean habits always record 1.
+type DailyValue struct {
+	Date  time.Time `json:"date"`
+	Value float64   `json:"value"`
+	Note  string    `json:"note"`
+}
+
+// CompletionEntry is one completion to insert during a backfill
+type CompletionEntry struct {
+	HabitID int
+	Date    time.Time
+	Value   float64
+	Note    string
+}
@@ -1,12 +1,109 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// HabitType says how a habit is recorded
+type HabitType string
+
+const (
+	// HabitBoolean habits are either done or not on a given day
+	HabitBoolean HabitType = "boolean"
+	// HabitNumeric habits record a value per day that is compared against a target
+	HabitNumeric HabitType = "numeric"
+)
+
+// HabitKind says whether a habit is something to do or something to stop doing
+type HabitKind string
+
+const (
+	// HabitBuild habits are tracked by completions
+	HabitBuild HabitKind = "build"
+	// HabitQuit habits are tracked by slips; every day without one is a success
+	HabitQuit HabitKind = "quit"
+)
+
+// Comparison says how a numeric habit's daily total is checked against its target
+type Comparison string
+
+const (
+	AtLeast Comparison = "at_least"
+	AtMost  Comparison = "at_most"
+)
 
 type Habit struct {
-	ID          int       `json:"id"`
-	UserID      int       `json:"user_id"`
-	Title       string    `json:"title" binding:"required"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-}
\ No newline at end of file
+	ID          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	Title       string     `json:"title" binding:"required"`
+	Description string     `json:"description"`
+	Kind        HabitKind  `json:"kind"`
+	Type        HabitType  `json:"type"`
+	Target      float64    `json:"target,omitempty"`
+	Unit        string     `json:"unit,omitempty"`
+	Comparison  Comparison `json:"comparison,omitempty"`
+	Schedule    Schedule   `json:"schedule"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Normalize fills in the defaults for fields a client left out
+func (h *Habit) Normalize() {
+	if h.Kind == "" {
+		h.Kind = HabitBuild
+	}
+	if h.Type == "" {
+		h.Type = HabitBoolean
+	}
+	if h.Type == HabitBoolean {
+		h.Target, h.Unit, h.Comparison = 0, "", ""
+	} else if h.Comparison == "" {
+		h.Comparison = AtLeast
+	}
+	if h.Schedule.Type == "" {
+		h.Schedule = DailySchedule()
+	}
+}
+
+// Validate checks the habit kind, type, target and schedule are consistent
+func (h *Habit) Validate() error {
+	switch h.Kind {
+	case HabitBuild:
+	case HabitQuit:
+		if h.Type != HabitBoolean {
+			return errors.New("quit habits cannot be numeric")
+		}
+		if !h.Schedule.IsDaily() {
+			return errors.New("quit habits are tracked every day and cannot have a schedule")
+		}
+	default:
+		return fmt.Errorf("unknown habit kind %q", h.Kind)
+	}
+
+	switch h.Type {
+	case HabitBoolean:
+	case HabitNumeric:
+		if h.Target <= 0 {
+			return errors.New("numeric habits need a target greater than 0")
+		}
+		if h.Comparison != AtLeast && h.Comparison != AtMost {
+			return fmt.Errorf("comparison must be %q or %q", AtLeast, AtMost)
+		}
+	default:
+		return fmt.Errorf("unknown habit type %q", h.Type)
+	}
+	return h.Schedule.Validate()
+}
+
+// MeetsTarget reports whether a day with the given total counts as complete
+func (h *Habit) MeetsTarget(total float64) bool {
+	if h.Type != HabitNumeric {
+		return true
+	}
+	if h.Comparison == AtMost {
+		return total <= h.Target
+	}
+	return total >= h.Target
+}
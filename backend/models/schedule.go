@@ -0,0 +1,100 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ScheduleType describes how often a habit is due
+type ScheduleType string
+
+const (
+	ScheduleDaily         ScheduleType = "daily"
+	ScheduleWeekdays      ScheduleType = "weekdays"
+	ScheduleTimesPerWeek  ScheduleType = "times_per_week"
+	ScheduleTimesPerMonth ScheduleType = "times_per_month"
+	ScheduleEveryNDays    ScheduleType = "every_n_days"
+)
+
+// Schedule says on which days a habit is due.
+//
+// Weekdays uses time.Weekday numbering (0 = Sunday) and is only used by
+// the weekdays type. Times is the target for the per-week and per-month
+// types, and Interval is N for every_n_days.
+type Schedule struct {
+	Type     ScheduleType   `json:"type"`
+	Weekdays []time.Weekday `json:"weekdays,omitempty"`
+	Times    int            `json:"times,omitempty"`
+	Interval int            `json:"interval,omitempty"`
+}
+
+// DailySchedule is the schedule of habits created without one
+func DailySchedule() Schedule {
+	return Schedule{Type: ScheduleDaily}
+}
+
+// Validate checks the schedule is complete and internally consistent
+func (s Schedule) Validate() error {
+	switch s.Type {
+	case ScheduleDaily:
+		return nil
+	case ScheduleWeekdays:
+		if len(s.Weekdays) == 0 {
+			return errors.New("weekdays schedule needs at least one weekday")
+		}
+		for _, d := range s.Weekdays {
+			if d < time.Sunday || d > time.Saturday {
+				return fmt.Errorf("invalid weekday %d, use 0 (Sunday) to 6 (Saturday)", d)
+			}
+		}
+		return nil
+	case ScheduleTimesPerWeek:
+		if s.Times < 1 || s.Times > 7 {
+			return errors.New("times_per_week schedule needs times between 1 and 7")
+		}
+		return nil
+	case ScheduleTimesPerMonth:
+		if s.Times < 1 || s.Times > 31 {
+			return errors.New("times_per_month schedule needs times between 1 and 31")
+		}
+		return nil
+	case ScheduleEveryNDays:
+		if s.Interval < 1 {
+			return errors.New("every_n_days schedule needs an interval of at least 1")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown schedule type %q", s.Type)
+	}
+}
+
+// IsDaily reports whether the habit is due every day
+func (s Schedule) IsDaily() bool {
+	return s.Type == ScheduleDaily || (s.Type == ScheduleEveryNDays && s.Interval == 1)
+}
+
+// Covers reports whether the schedule can ask for the habit on weekday d; only a
+// weekdays schedule leaves days out
+func (s Schedule) Covers(d time.Weekday) bool {
+	return s.Type != ScheduleWeekdays || s.WeekdayMask()&(1<<d) != 0
+}
+
+// WeekdayMask packs Weekdays into a bitmask, bit 0 being Sunday
+func (s Schedule) WeekdayMask() int {
+	mask := 0
+	for _, d := range s.Weekdays {
+		mask |= 1 << d
+	}
+	return mask
+}
+
+// SetWeekdayMask unpacks a bitmask produced by WeekdayMask into Weekdays
+func (s *Schedule) SetWeekdayMask(mask int) {
+	s.Weekdays = nil
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if mask&(1<<d) != 0 {
+			s.Weekdays = append(s.Weekdays, d)
+		}
+	}
+}
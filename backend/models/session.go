@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Session is one refresh token issued to a user. Only a hash of the token is kept.
+// Sessions created by rotating a refresh token share the FamilyID of the login
+// that started the chain.
+type Session struct {
+	ID              int
+	UserID          int
+	FamilyID        string
+	TokenHash       string
+	AccessJTI       string // ID of the access token issued together with this refresh token
+	AccessExpiresAt time.Time
+	CreatedAt       time.Time
+	ExpiresAt       time.Time
+	RevokedAt       *time.Time
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Granularity is the length of the periods statistics are grouped into
+type Granularity string
+
+const (
+	GranularityWeek  Granularity = "week"
+	GranularityMonth Granularity = "month"
+	GranularityYear  Granularity = "year"
+)
+
+// ParseGranularity accepts week, month or year; empty means month
+func ParseGranularity(s string) (Granularity, error) {
+	switch g := Granularity(s); g {
+	case "":
+		return GranularityMonth, nil
+	case GranularityWeek, GranularityMonth, GranularityYear:
+		return g, nil
+	}
+	return "", fmt.Errorf("granularity must be %q, %q or %q", GranularityWeek, GranularityMonth, GranularityYear)
+}
+
+// Truncate returns the first day of the period containing day. Weeks start on Monday.
+func (g Granularity) Truncate(day time.Time) time.Time {
+	y, m, d := day.Date()
+	switch g {
+	case GranularityWeek:
+		return time.Date(y, m, d-(int(day.Weekday())+6)%7, 0, 0, 0, 0, time.UTC)
+	case GranularityYear:
+		return time.Date(y, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Next returns the first day of the period after the one starting on start
+func (g Granularity) Next(start time.Time) time.Time {
+	switch g {
+	case GranularityWeek:
+		return start.AddDate(0, 0, 7)
+	case GranularityYear:
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// PeriodCount is how many days of one period a habit met its target,
+// or for quit habits how many slips it had
+type PeriodCount struct {
+	HabitID int
+	Period  time.Time // first day of the period
+	Count   int
+	First   time.Time // earliest day counted
+}
+
+// CheckIn is when a completion was recorded, which for completions backdated with
+// a date can be a later day than the one it counts for
+type CheckIn struct {
+	HabitID     int
+	Date        time.Time // the day the completion counts for
+	CompletedAt time.Time
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// Streak is the persisted streak record for a single habit.
+// For quit habits LastCompleted is the last slip, nil if there was none.
+type Streak struct {
+	HabitID       int        `json:"habit_id"`
+	UserID        int        `json:"user_id"`
+	CurrentStreak int        `json:"current_streak"`
+	LongestStreak int        `json:"longest_streak"`
+	LastCompleted *time.Time `json:"last_completed"`
+	// Strength is the habit's strength score from 0 to 100
+	Strength float64 `json:"strength"`
+}
+
+// HabitWithStreak pairs a habit with its streak record, if one exists
+type HabitWithStreak struct {
+	Habit  Habit
+	Streak *Streak
+}
+
+// DayStrength is a habit's strength score at the end of one day
+type DayStrength struct {
+	Date     time.Time
+	Strength float64
+}
+
+// MarshalJSON writes the day as a plain date
+func (d DayStrength) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Date     string  `json:"date"`
+		Strength float64 `json:"strength"`
+	}{d.Date.Format("2006-01-02"), RoundStrength(d.Strength)})
+}
+
+// RoundStrength rounds a strength score to two decimals for display
+func RoundStrength(s float64) float64 {
+	return math.Round(s*100) / 100
+}
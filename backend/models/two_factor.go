@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// TwoFactor is a user's TOTP enrolment. It only protects logins once Enabled.
+type TwoFactor struct {
+	UserID    int
+	Secret    string
+	Enabled   bool
+	LastStep  int64 // the last TOTP period a code was accepted for
+	CreatedAt time.Time
+}
@@ -1,12 +1,68 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultTimeZone is used for users who have not picked a time zone
+const DefaultTimeZone = "UTC"
 
 // User represents a user in the system
 type User struct {
-	ID        int       `json:"id"`
-	Username  string    `json:"username" binding:"required"`
-	Email     string    `json:"email" binding:"required,email"`
-	Password  string    `json:"password" binding:"required"`
-	CreatedAt time.Time `json:"created_at"`
-}
\ No newline at end of file
+	ID       int    `json:"id"`
+	Username string `json:"username" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+	TimeZone string `json:"time_zone"`
+	// EmailVerified is only ever set by following the link in the verification email
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Purposes of a UserToken
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+	TokenChangeEmail   = "change_email"
+	// The one-time codes single sign-on hands the frontend, for logging in or re-authenticating
+	TokenOIDCLogin  = "oidc_login"
+	TokenOIDCReauth = "oidc_reauth"
+)
+
+// UserToken is a single-use secret mailed or handed to a user. Only a hash of it is stored.
+type UserToken struct {
+	ID        int
+	UserID    int
+	Purpose   string
+	TokenHash string
+	Email     string // new address, for TokenChangeEmail
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// Location returns the user's time zone, falling back to UTC if it is unset or unknown
+func (u *User) Location() *time.Location {
+	loc, err := ParseTimeZone(u.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ParseTimeZone loads an IANA time zone name such as "Europe/Berlin"; empty means UTC.
+// "Local" is refused, as it would silently mean whatever zone the server runs in.
+func ParseTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	if name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %q, use an IANA name such as \"Europe/Berlin\"", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return loc, nil
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"testing"
+	_ "time/tzdata"
+)
+
+func TestParseTimeZone(t *testing.T) {
+	tests := []struct {
+		name string
+		want string // location name, empty if the zone must be refused
+	}{
+		{"", "UTC"},
+		{"UTC", "UTC"},
+		{"Europe/Berlin", "Europe/Berlin"},
+		{"America/New_York", "America/New_York"},
+		{"Local", ""},
+		{"Mars/Olympus_Mons", ""},
+		{"../../etc/passwd", ""},
+	}
+
+	for _, tt := range tests {
+		loc, err := ParseTimeZone(tt.name)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("ParseTimeZone(%q) = %v, want an error", tt.name, loc)
+		case tt.want != "" && err != nil:
+			t.Errorf("ParseTimeZone(%q) failed: %v", tt.name, err)
+		case tt.want != "" && loc.String() != tt.want:
+			t.Errorf("ParseTimeZone(%q) = %v, want %s", tt.name, loc, tt.want)
+		}
+	}
+}
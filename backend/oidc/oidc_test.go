@@ -0,0 +1,315 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID     = "habits"
+	testClientSecret = "s3cret"
+	testRedirect     = "http://localhost:8080/auth/oidc/callback"
+)
+
+var (
+	keyOnce          sync.Once
+	signKey, rogue   *rsa.PrivateKey
+	errKeyGeneration error
+)
+
+// testKeys generates the provider's signing key and an unrelated one, once per run
+func testKeys(t *testing.T) (*rsa.PrivateKey, *rsa.PrivateKey) {
+	t.Helper()
+	keyOnce.Do(func() {
+		if signKey, errKeyGeneration = rsa.GenerateKey(rand.Reader, 2048); errKeyGeneration == nil {
+			rogue, errKeyGeneration = rsa.GenerateKey(rand.Reader, 2048)
+		}
+	})
+	if errKeyGeneration != nil {
+		t.Fatal(errKeyGeneration)
+	}
+	return signKey, rogue
+}
+
+// mockIdP serves discovery, JWKS and token endpoints. The token endpoint checks the
+// client and PKCE verifier, then answers with whatever idToken returns.
+type mockIdP struct {
+	*httptest.Server
+	t             *testing.T
+	key           *rsa.PrivateKey
+	issuer        string // the issuer the discovery document claims, the server URL by default
+	code          string
+	nonce         string // the nonce of the authorization request, signed into the ID token
+	chal          string // the PKCE challenge of the authorization request
+	idToken       func(m *mockIdP) string
+	tokenResponse map[string]string // replaces the whole token response when set
+
+	jwksRequests int
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	key, _ := testKeys(t)
+	m := &mockIdP{t: t, key: key, code: "auth-code"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer := m.issuer
+		if issuer == "" {
+			issuer = m.URL
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"jwks_uri":               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		m.jwksRequests++
+		pub := m.key.PublicKey
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "k1", "use": "sig", "alg": "RS256",
+				"n": base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())},
+			// Encryption keys and unknown types must be skipped, not fail the set
+			{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+			{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": "AAAA"},
+		}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("token request: %v", err)
+		}
+		user, pass, _ := r.BasicAuth()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		switch {
+		case m.tokenResponse != nil:
+			json.NewEncoder(w).Encode(m.tokenResponse)
+		case user != testClientID || pass != testClientSecret:
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		case r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("code") != m.code ||
+			r.PostForm.Get("redirect_uri") != testRedirect ||
+			base64.RawURLEncoding.EncodeToString(sum[:]) != m.chal:
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "bad code or verifier"})
+		default:
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "token_type": "Bearer", "id_token": m.idToken(m)})
+		}
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	m.idToken = func(m *mockIdP) string { return m.sign(m.claims(), "k1", m.key) }
+	return m
+}
+
+// claims are valid ID token claims for the current authorization request
+func (m *mockIdP) claims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            m.URL,
+		"sub":            "user-42",
+		"aud":            testClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          m.nonce,
+		"email":          "ann@example.com",
+		"email_verified": "true",
+		"name":           "Ann",
+	}
+}
+
+func (m *mockIdP) sign(claims jwt.MapClaims, kid string, key *rsa.PrivateKey) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	raw, err := token.SignedString(key)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return raw
+}
+
+// authorize starts a flow the way a browser would, recording what the provider sees
+func (m *mockIdP) authorize(t *testing.T, p *Provider, reauth bool) Flow {
+	t.Helper()
+	flow, err := NewFlow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	flow.Reauth = reauth
+	raw, err := p.AuthCodeURL(context.Background(), flow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Path != "/authorize" || q.Get("client_id") != testClientID || q.Get("redirect_uri") != testRedirect ||
+		q.Get("state") != flow.State || q.Get("code_challenge_method") != "S256" || !strings.Contains(q.Get("scope"), "openid") {
+		t.Fatalf("unexpected authorization URL %s", raw)
+	}
+	if got := q.Get("prompt") == "login"; got != reauth {
+		t.Errorf("prompt=login is %v, want %v", got, reauth)
+	}
+	m.nonce, m.chal = q.Get("nonce"), q.Get("code_challenge")
+	return flow
+}
+
+func newTestProvider(m *mockIdP) *Provider {
+	return New(Config{Issuer: m.URL + "/", ClientID: testClientID, ClientSecret: testClientSecret, RedirectURL: testRedirect})
+}
+
+func TestExchange(t *testing.T) {
+	m := newMockIdP(t)
+	p := newTestProvider(m)
+
+	for _, reauth := range []bool{false, true} {
+		flow := m.authorize(t, p, reauth)
+		claims, err := p.Exchange(context.Background(), m.code, flow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := Claims{Issuer: m.URL, Subject: "user-42", Email: "ann@example.com", EmailVerified: true, Name: "Ann"}
+		if *claims != want {
+			t.Errorf("claims = %+v, want %+v", *claims, want)
+		}
+	}
+	if m.jwksRequests != 1 {
+		t.Errorf("fetched the JWKS %d times, want once", m.jwksRequests)
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	_, rogue := testKeys(t)
+
+	tests := []struct {
+		name  string
+		setup func(m *mockIdP, flow *Flow)
+	}{
+		{"wrong nonce", func(m *mockIdP, _ *Flow) {
+			m.idToken = func(m *mockIdP) string {
+				c := m.claims()
+				c["nonce"] = "replayed"
+				return m.sign(c, "k1", m.key)
+			}
+		}},
+		{"other issuer", func(m *mockIdP, _ *Flow) {
+			m.idToken = func(m *mockIdP) string {
+				c := m.claims()
+				c["iss"] = "https://evil.example.com"
+				return m.sign(c, "k1", m.key)
+			}
+		}},
+		{"other audience", func(m *mockIdP, _ *Flow) {
+			m.idToken = func(m *mockIdP) string {
+				c := m.claims()
+				c["aud"] = "someone-else"
+				return m.sign(c, "k1", m.key)
+			}
+		}},
+		{"expired", func(m *mockIdP, _ *Flow) {
+			m.idToken = func(m *mockIdP) string {
+				c := m.claims()
+				c["exp"] = time.Now().Add(-time.Minute).Unix()
+				return m.sign(c, "k1", m.key)
+			}
+		}},
+		{"no subject", func(m *mockIdP, _ *Flow) {
+			m.idToken = func(m *mockIdP) string {
+				c := m.claims()
+				delete(c, "sub")
+				return m.sign(c, "k1", m.key)
+			}
+		}},
+		{"signed by another key", func(m *mockIdP, _ *Flow) {
+			m.idToken = func(m *mockIdP) string { return m.sign(m.claims(), "k1", rogue) }
+		}},
+		{"unknown key id", func(m *mockIdP, _ *Flow) {
+			m.idToken = func(m *mockIdP) string { return m.sign(m.claims(), "k9", rogue) }
+		}},
+		{"symmetric algorithm", func(m *mockIdP, _ *Flow) {
+			m.idToken = func(m *mockIdP) string {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, m.claims())
+				token.Header["kid"] = "k1"
+				raw, _ := token.SignedString([]byte(testClientSecret))
+				return raw
+			}
+		}},
+		{"wrong PKCE verifier", func(_ *mockIdP, flow *Flow) { flow.Verifier = "guessed" }},
+		{"no id token", func(m *mockIdP, _ *Flow) {
+			m.tokenResponse = map[string]string{"access_token": "at"}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockIdP(t)
+			p := newTestProvider(m)
+			flow := m.authorize(t, p, false)
+			tt.setup(m, &flow)
+			if claims, err := p.Exchange(context.Background(), m.code, flow); err == nil {
+				t.Fatalf("Exchange accepted %+v", claims)
+			}
+		})
+	}
+}
+
+func TestExchangeReportsProviderErrors(t *testing.T) {
+	m := newMockIdP(t)
+	p := newTestProvider(m)
+	flow := m.authorize(t, p, false)
+	m.tokenResponse = map[string]string{"error": "invalid_grant", "error_description": "code expired"}
+
+	_, err := p.Exchange(context.Background(), m.code, flow)
+	if !errors.Is(err, ErrProvider) || !strings.Contains(err.Error(), "code expired") {
+		t.Errorf("error = %v, want ErrProvider with the description", err)
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	m := newMockIdP(t)
+	m.issuer = "https://evil.example.com"
+	p := newTestProvider(m)
+
+	if _, err := p.AuthCodeURL(context.Background(), Flow{}); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("error = %v, want an issuer mismatch", err)
+	}
+}
+
+func TestUnknownKeyIsNotRefetchedAtOnce(t *testing.T) {
+	m := newMockIdP(t)
+	p := newTestProvider(m)
+	flow := m.authorize(t, p, false)
+	if _, err := p.Exchange(context.Background(), m.code, flow); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tokens naming made-up keys must not make us hammer the provider
+	_, rogue := testKeys(t)
+	m.idToken = func(m *mockIdP) string { return m.sign(m.claims(), "k9", rogue) }
+	for range 3 {
+		if _, err := p.Exchange(context.Background(), m.code, flow); err == nil {
+			t.Fatal("token with an unknown key accepted")
+		}
+	}
+	if m.jwksRequests != 1 {
+		t.Errorf("fetched the JWKS %d times, want once", m.jwksRequests)
+	}
+}
@@ -0,0 +1,185 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// idTokenClaims is the JSON form of an ID token
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce             string `json:"nonce"`
+	Email             string `json:"email"`
+	EmailVerified     any    `json:"email_verified"` // some providers send "true" as a string
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// verify checks the ID token's signature and the claims OIDC Core 3.1.3.7 requires
+func (p *Provider) verify(ctx context.Context, meta *metadata, raw, nonce string) (*Claims, error) {
+	var claims idTokenClaims
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "PS256"}),
+		jwt.WithIssuer(meta.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	_, err := parser.ParseWithClaims(raw, &claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.keys.get(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("oidc id token: %w", err)
+	}
+
+	switch {
+	case claims.Nonce != nonce:
+		return nil, errors.New("oidc id token: nonce does not match")
+	case claims.Subject == "":
+		return nil, errors.New("oidc id token: no subject")
+	}
+
+	return &Claims{
+		Issuer:            p.cfg.Issuer,
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     claims.EmailVerified == true || claims.EmailVerified == "true",
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+	}, nil
+}
+
+// keySet caches the provider's signing keys, refetching them when a token names an
+// unknown key so rotations are picked up
+type keySet struct {
+	uri   string
+	fetch func(ctx context.Context, rawURL string, v any) error
+
+	mu        sync.Mutex
+	keys      map[string]any
+	fetchedAt time.Time
+}
+
+// minRefetch stops tokens with made-up key ids from hammering the provider
+const minRefetch = time.Minute
+
+func (s *keySet) get(ctx context.Context, kid string) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key := s.lookup(kid); key != nil {
+		return key, nil
+	}
+	if time.Since(s.fetchedAt) < minRefetch {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if err := s.refresh(ctx); err != nil {
+		return nil, err
+	}
+	if key := s.lookup(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookup finds a key by id; tokens without a kid are accepted if there is only one key
+func (s *keySet) lookup(kid string) any {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key
+		}
+	}
+	return s.keys[kid]
+}
+
+func (s *keySet) refresh(ctx context.Context) error {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := s.fetch(ctx, s.uri, &doc); err != nil {
+		return fmt.Errorf("oidc jwks: %w", err)
+	}
+	s.fetchedAt = time.Now()
+	s.keys = make(map[string]any)
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// Keys of types we cannot use are skipped rather than failing the whole set
+		if key, err := k.publicKey(); err == nil {
+			s.keys[k.Kid] = key
+		}
+	}
+	return nil
+}
+
+// jwk is a public key in JSON Web Key form (RFC 7517)
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, errors.New("rsa exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("ec point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// idleTimeout is how long unused entries are kept before being dropped
+const idleTimeout = 24 * time.Hour
+
+// MemoryStore keeps everything in process memory. It is the default and is
+// enough for a single replica.
+type MemoryStore struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	buckets   map[string]*bucket
+	failures  map[string]*failureState
+	lastPrune time.Time
+}
+
+type failureState struct {
+	count       int
+	lockedUntil time.Time
+	updated     time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		now:      time.Now,
+		buckets:  make(map[string]*bucket),
+		failures: make(map[string]*failureState),
+	}
+}
+
+func (s *MemoryStore) Allow(ctx context.Context, key string, limit Limit) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.prune(now)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	return b.take(limit, now), nil
+}
+
+func (s *MemoryStore) RecordFailure(ctx context.Context, key string, backoff Backoff) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	f, ok := s.failures[key]
+	if !ok || backoff.expired(f.updated, f.lockedUntil, now) {
+		f = &failureState{}
+		s.failures[key] = f
+	}
+	f.count++
+	f.updated = now
+	lockout := backoff.lockout(f.count)
+	if lockout > 0 {
+		f.lockedUntil = now.Add(lockout)
+	}
+	return lockout, nil
+}
+
+func (s *MemoryStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f, ok := s.failures[key]; ok {
+		return max(f.lockedUntil.Sub(s.now()), 0), nil
+	}
+	return 0, nil
+}
+
+func (s *MemoryStore) ResetFailures(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.failures, key)
+	return nil
+}
+
+// prune drops idle entries at most once a minute; s.mu must be held
+func (s *MemoryStore) prune(now time.Time) {
+	if now.Sub(s.lastPrune) < time.Minute {
+		return
+	}
+	s.lastPrune = now
+	for key, b := range s.buckets {
+		if now.Sub(b.updated) > idleTimeout {
+			delete(s.buckets, key)
+		}
+	}
+	for key, f := range s.failures {
+		if now.Sub(f.updated) > idleTimeout && now.After(f.lockedUntil) {
+			delete(s.failures, key)
+		}
+	}
+}
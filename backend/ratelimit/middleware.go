@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyFunc names the bucket a request draws from; an empty key skips the limit
+type KeyFunc func(c *gin.Context) string
+
+// ByIP keys requests on the client address
+func ByIP(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// ByUser keys requests on the user set by the auth middleware
+func ByUser(c *gin.Context) string {
+	if userID, ok := c.Get("user_id"); ok {
+		return fmt.Sprint(userID)
+	}
+	return ""
+}
+
+// Middleware rejects requests over limit with 429 Too Many Requests. name keeps
+// the buckets of different limits apart.
+func Middleware(s Store, name string, limit Limit, key KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		k := key(c)
+		if k == "" {
+			c.Next()
+			return
+		}
+
+		wait, err := s.Allow(c.Request.Context(), name+":"+k, limit)
+		if err != nil {
+			// Better to serve the request than to fail everything when the store is down
+			log.Printf("⚠️  Rate limit check failed: %v", err)
+			c.Next()
+			return
+		}
+		if wait > 0 {
+			TooManyRequests(c, wait)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// TooManyRequests writes a 429 response telling the client when to retry
+func TooManyRequests(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, try again later"})
+}
@@ -0,0 +1,86 @@
+// Package ratelimit throttles requests with token buckets and locks out keys,
+// such as an account being guessed at, after repeated failures.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit is a token bucket: Burst requests at once, refilled at Rate per second
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute allows n requests a minute, all of which may come at once
+func PerMinute(n int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: n}
+}
+
+// PerHour allows n requests an hour, all of which may come at once
+func PerHour(n int) Limit {
+	return Limit{Rate: float64(n) / 3600, Burst: n}
+}
+
+// Backoff locks a key out once it reaches Threshold consecutive failures, for Base
+// at first and twice as long with every further failure, up to Max. A failure more
+// than Window after the previous one, with no lockout running, starts the count over.
+type Backoff struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+	Window    time.Duration
+}
+
+// expired reports whether failures last counted at updated and locked until
+// lockedUntil are forgotten by now
+func (b Backoff) expired(updated, lockedUntil, now time.Time) bool {
+	return now.Sub(updated) > b.Window && !now.Before(lockedUntil)
+}
+
+// lockout returns how long a key with the given number of failures stays locked
+func (b Backoff) lockout(failures int) time.Duration {
+	if failures < b.Threshold {
+		return 0
+	}
+	d := float64(b.Base) * math.Pow(2, float64(failures-b.Threshold))
+	if d > float64(b.Max) {
+		return b.Max
+	}
+	return time.Duration(d)
+}
+
+// Store keeps the buckets and failure counters
+type Store interface {
+	// Allow takes a token from key's bucket. It returns zero if the request may go
+	// ahead, and otherwise how long to wait before retrying.
+	Allow(ctx context.Context, key string, limit Limit) (time.Duration, error)
+	// RecordFailure counts a failed attempt and returns how long key is now locked out
+	RecordFailure(ctx context.Context, key string, backoff Backoff) (time.Duration, error)
+	// LockedFor returns how much longer key is locked out, zero if it is not
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+	// ResetFailures clears key's failures after a success
+	ResetFailures(ctx context.Context, key string) error
+}
+
+// bucket is the state of one token bucket
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take refills b up to now and takes a token, returning the wait if there was none
+func (b *bucket) take(limit Limit, now time.Time) time.Duration {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+	}
+	b.updated = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	wait := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	return max(wait, time.Second)
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"context"
+	"habit-tracker/backend/migrations"
+	"habit-tracker/backend/store"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testBackoff = Backoff{Threshold: 3, Base: time.Minute, Max: 4 * time.Minute, Window: time.Hour}
+
+// testStores returns a memory and a SQLite store sharing the clock *now
+func testStores(t *testing.T, now *time.Time) map[string]Store {
+	t.Helper()
+	clock := func() time.Time { return *now }
+
+	mem := NewMemoryStore()
+	mem.now = clock
+
+	db, dialect, err := store.Open("sqlite://" + filepath.Join(t.TempDir(), "limits.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	runner, err := migrations.NewRunner(db, dialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Up(); err != nil {
+		t.Fatal(err)
+	}
+	sqlStore := NewSQLStore(db, dialect)
+	sqlStore.now = clock
+
+	return map[string]Store{"memory": mem, "sql": sqlStore}
+}
+
+func TestBackoffLockout(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, 0}, {2, 0}, {3, time.Minute}, {4, 2 * time.Minute}, {5, 4 * time.Minute}, {9, 4 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := testBackoff.lockout(tt.failures); got != tt.want {
+			t.Errorf("lockout(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestRecordFailure(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	for name, s := range testStores(t, &now) {
+		t.Run(name, func(t *testing.T) {
+			start := now
+			t.Cleanup(func() { now = start })
+			fail := func(want time.Duration) {
+				t.Helper()
+				got, err := s.RecordFailure(ctx, "login:ann", testBackoff)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Fatalf("lockout = %v, want %v", got, want)
+				}
+			}
+
+			fail(0)
+			fail(0)
+			fail(time.Minute)
+			if locked, _ := s.LockedFor(ctx, "login:ann"); locked != time.Minute {
+				t.Fatalf("locked for %v, want a minute", locked)
+			}
+			now = now.Add(2 * time.Minute)
+			fail(2 * time.Minute)
+			fail(4 * time.Minute)
+
+			// A quiet hour after the lockout ends forgets the failures
+			now = now.Add(4*time.Minute + time.Hour + time.Second)
+			if locked, _ := s.LockedFor(ctx, "login:ann"); locked != 0 {
+				t.Fatalf("still locked for %v", locked)
+			}
+			fail(0)
+			fail(0)
+			fail(time.Minute)
+
+			// Failures spaced within the window keep counting
+			now = now.Add(59 * time.Minute)
+			fail(2 * time.Minute)
+
+			if err := s.ResetFailures(ctx, "login:ann"); err != nil {
+				t.Fatal(err)
+			}
+			fail(0)
+		})
+	}
+}
+
+func TestAllow(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	limit := PerMinute(2)
+
+	for name, s := range testStores(t, &now) {
+		t.Run(name, func(t *testing.T) {
+			for i, want := range []bool{true, true, false} {
+				wait, err := s.Allow(ctx, "register:ann", limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if (wait == 0) != want {
+					t.Fatalf("request %d: wait %v, want allowed %v", i+1, wait, want)
+				}
+			}
+			// A token comes back every half minute
+			now = now.Add(30 * time.Second)
+			if wait, _ := s.Allow(ctx, "register:ann", limit); wait != 0 {
+				t.Errorf("after refill: wait %v", wait)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"habit-tracker/backend/store"
+	"sync"
+	"time"
+)
+
+// SQLStore keeps buckets and failures in the database so that every replica
+// shares them. Times are stored as Unix microseconds.
+type SQLStore struct {
+	db      *sql.DB
+	dialect store.Dialect
+	now     func() time.Time
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// NewSQLStore returns a Store using the rate_limit_* tables of db
+func NewSQLStore(db *sql.DB, dialect store.Dialect) *SQLStore {
+	return &SQLStore{db: db, dialect: dialect, now: time.Now}
+}
+
+func (s *SQLStore) Allow(ctx context.Context, key string, limit Limit) (time.Duration, error) {
+	now := s.now()
+	s.prune(ctx, now)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO rate_limit_buckets (id, tokens, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO NOTHING
+	`, key, float64(limit.Burst), now.UnixMicro())
+	if err != nil {
+		return 0, err
+	}
+
+	// SQLite serialises writers on its own; PostgreSQL needs the row lock
+	query := `SELECT tokens, updated_at FROM rate_limit_buckets WHERE id = $1`
+	if s.dialect == store.Postgres {
+		query += ` FOR UPDATE`
+	}
+	var b bucket
+	var updated int64
+	if err := tx.QueryRowContext(ctx, query, key).Scan(&b.tokens, &updated); err != nil {
+		return 0, err
+	}
+	b.updated = time.UnixMicro(updated)
+
+	wait := b.take(limit, now)
+	_, err = tx.ExecContext(ctx, `UPDATE rate_limit_buckets SET tokens = $1, updated_at = $2 WHERE id = $3`,
+		b.tokens, b.updated.UnixMicro(), key)
+	if err != nil {
+		return 0, err
+	}
+	return wait, tx.Commit()
+}
+
+func (s *SQLStore) RecordFailure(ctx context.Context, key string, backoff Backoff) (time.Duration, error) {
+	now := s.now()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// The same test as Backoff.expired: a stale count without a lockout starts over
+	var failures int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO rate_limit_failures (id, failures, updated_at) VALUES ($1, 1, $2)
+		ON CONFLICT (id) DO UPDATE SET
+			failures = CASE
+				WHEN rate_limit_failures.updated_at < $3 AND rate_limit_failures.locked_until <= $2 THEN 1
+				ELSE rate_limit_failures.failures + 1
+			END,
+			locked_until = CASE
+				WHEN rate_limit_failures.updated_at < $3 AND rate_limit_failures.locked_until <= $2 THEN 0
+				ELSE rate_limit_failures.locked_until
+			END,
+			updated_at = EXCLUDED.updated_at
+		RETURNING failures
+	`, key, now.UnixMicro(), now.Add(-backoff.Window).UnixMicro()).Scan(&failures)
+	if err != nil {
+		return 0, err
+	}
+
+	lockout := backoff.lockout(failures)
+	if lockout > 0 {
+		_, err := tx.ExecContext(ctx, `UPDATE rate_limit_failures SET locked_until = $1 WHERE id = $2`,
+			now.Add(lockout).UnixMicro(), key)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return lockout, tx.Commit()
+}
+
+func (s *SQLStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	var lockedUntil int64
+	err := s.db.QueryRowContext(ctx, `SELECT locked_until FROM rate_limit_failures WHERE id = $1`, key).Scan(&lockedUntil)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return max(time.UnixMicro(lockedUntil).Sub(s.now()), 0), nil
+}
+
+func (s *SQLStore) ResetFailures(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM rate_limit_failures WHERE id = $1`, key)
+	return err
+}
+
+// prune deletes idle rows, at most every ten minutes per process
+func (s *SQLStore) prune(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastPrune) < 10*time.Minute {
+		s.mu.Unlock()
+		return
+	}
+	s.lastPrune = now
+	s.mu.Unlock()
+
+	cutoff := now.Add(-idleTimeout).UnixMicro()
+	s.db.ExecContext(ctx, `DELETE FROM rate_limit_buckets WHERE updated_at < $1`, cutoff)
+	s.db.ExecContext(ctx, `DELETE FROM rate_limit_failures WHERE updated_at < $1 AND locked_until < $2`, cutoff, now.UnixMicro())
+}
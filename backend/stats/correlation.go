@@ -0,0 +1,97 @@
+package stats
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// Correlation compares the days two habits were done over the days both were due.
+// For quit habits a day counts as done when it had a slip.
+type Correlation struct {
+	Days    int // days both habits were due, the sample size
+	Both    int
+	OnlyA   int
+	OnlyB   int
+	Neither int
+}
+
+// Correlate counts on which of the days that both habits were due a and b were done,
+// given whether each was done on every day of a range and which days of it were due
+func Correlate(a, b []bool, due func(day int) bool) Correlation {
+	var c Correlation
+	for d := range a {
+		if !due(d) {
+			continue
+		}
+		c.Days++
+		switch {
+		case a[d] && b[d]:
+			c.Both++
+		case a[d]:
+			c.OnlyA++
+		case b[d]:
+			c.OnlyB++
+		default:
+			c.Neither++
+		}
+	}
+	return c
+}
+
+// BGivenA is the share of the days a was done on that b was done on too, NaN if a never was
+func (c Correlation) BGivenA() float64 {
+	return ratio(c.Both, c.Both+c.OnlyA)
+}
+
+// AGivenB is the share of the days b was done on that a was done on too, NaN if b never was
+func (c Correlation) AGivenB() float64 {
+	return ratio(c.Both, c.Both+c.OnlyB)
+}
+
+// Lift is how much more often both were done than if they were independent,
+// NaN if either was never done
+func (c Correlation) Lift() float64 {
+	return ratio(c.Both*c.Days, (c.Both+c.OnlyA)*(c.Both+c.OnlyB))
+}
+
+// Phi is the correlation coefficient of the two habits from -1 to 1, NaN if either
+// was done on every day or on none, as then there is nothing to compare
+func (c Correlation) Phi() float64 {
+	a1, a0 := float64(c.Both+c.OnlyA), float64(c.OnlyB+c.Neither)
+	b1, b0 := float64(c.Both+c.OnlyB), float64(c.OnlyA+c.Neither)
+	denominator := math.Sqrt(a1 * a0 * b1 * b0)
+	if denominator == 0 {
+		return math.NaN()
+	}
+	return (float64(c.Both)*float64(c.Neither) - float64(c.OnlyA)*float64(c.OnlyB)) / denominator
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return math.NaN()
+	}
+	return float64(n) / float64(d)
+}
+
+// round4 rounds to four decimals, or to nil for NaN so JSON gets null
+func round4(x float64) *float64 {
+	if math.IsNaN(x) {
+		return nil
+	}
+	x = math.Round(x*10000) / 10000
+	return &x
+}
+
+func (c Correlation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Days    int      `json:"days"`
+		Both    int      `json:"both"`
+		OnlyA   int      `json:"only_a"`
+		OnlyB   int      `json:"only_b"`
+		Neither int      `json:"neither"`
+		BGivenA *float64 `json:"p_b_given_a"`
+		AGivenB *float64 `json:"p_a_given_b"`
+		Lift    *float64 `json:"lift"`
+		Phi     *float64 `json:"phi"`
+	}{c.Days, c.Both, c.OnlyA, c.OnlyB, c.Neither, round4(c.BGivenA()), round4(c.AGivenB()), round4(c.Lift()), round4(c.Phi())})
+}
@@ -0,0 +1,195 @@
+// Package stats turns the completion counts aggregated by the database into
+// completion rates per week, month or year and over rolling windows.
+//
+// How many completions were due comes from the habit's schedule. Flexible
+// schedules such as three times a week are prorated by day, so a period that
+// is cut short by the start of tracking or by today owes its share only.
+package stats
+
+import (
+	"encoding/json"
+	"habit-tracker/backend/models"
+	"math"
+	"time"
+)
+
+// Counts are the completions done and due over some span of days
+type Counts struct {
+	Completed int
+	Due       int
+}
+
+// Rate is the percentage of due completions that were done, 0 if none were due
+func (c Counts) Rate() float64 {
+	if c.Due == 0 {
+		return 0
+	}
+	return math.Round(float64(c.Completed)/float64(c.Due)*10000) / 100
+}
+
+// add sums two counts
+func (c Counts) add(o Counts) Counts {
+	return Counts{Completed: c.Completed + o.Completed, Due: c.Due + o.Due}
+}
+
+func (c Counts) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Completed int     `json:"completed"`
+		Due       int     `json:"due"`
+		Rate      float64 `json:"rate"`
+	}{c.Completed, c.Due, c.Rate()})
+}
+
+// Period is the counts of one week, month or year
+type Period struct {
+	Start time.Time // first day
+	End   time.Time // last day
+	Counts
+}
+
+func (p Period) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Start     string  `json:"start"`
+		End       string  `json:"end"`
+		Completed int     `json:"completed"`
+		Due       int     `json:"due"`
+		Rate      float64 `json:"rate"`
+	}{p.Start.Format("2006-01-02"), p.End.Format("2006-01-02"), p.Completed, p.Due, p.Rate()})
+}
+
+// Start returns the first day a habit is tracked from: its creation day in loc,
+// or its first counted day if that is earlier. first is zero if there is none.
+func Start(habit *models.Habit, loc *time.Location, first time.Time) time.Time {
+	created := habit.CreatedAt.In(loc)
+	start := time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, time.UTC)
+	if !first.IsZero() && first.Before(start) {
+		return first
+	}
+	return start
+}
+
+// Window returns the counts of habit from from to to inclusive, given how many days
+// the database counted in that span, which must leave out the days its schedule does
+// not cover. Days before start are not due.
+func Window(habit *models.Habit, start, from, to time.Time, counted int) Counts {
+	if from.Before(start) {
+		from = start
+	}
+	if to.Before(from) {
+		return Counts{}
+	}
+	if habit.Kind == models.HabitQuit {
+		// counted are the slips, every other day is a success
+		days := int(to.Sub(from).Hours()/24) + 1
+		return Counts{Completed: max(days-counted, 0), Due: days}
+	}
+	due := Due(habit.Schedule, from, to)
+	// Completions beyond what the schedule asked for do not raise the rate past 100%
+	return Counts{Completed: min(counted, due), Due: due}
+}
+
+// Due returns how many completions schedule asks for from from to to inclusive
+func Due(schedule models.Schedule, from, to time.Time) int {
+	due := 0.0
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		switch schedule.Type {
+		case models.ScheduleWeekdays:
+			if schedule.Covers(d.Weekday()) {
+				due++
+			}
+		case models.ScheduleTimesPerWeek:
+			due += float64(schedule.Times) / 7
+		case models.ScheduleTimesPerMonth:
+			daysInMonth := time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+			due += float64(schedule.Times) / float64(daysInMonth)
+		case models.ScheduleEveryNDays:
+			due += 1 / float64(schedule.Interval)
+		default:
+			due++
+		}
+	}
+	return int(math.Round(due))
+}
+
+// Periods returns the counts of every period from the one containing start up to
+// the one containing today. counts maps the first day of a period to the days the
+// database counted in it.
+func Periods(habit *models.Habit, g models.Granularity, start, today time.Time, counts map[time.Time]int) []Period {
+	periods := []Period{}
+	for p := g.Truncate(start); !p.After(today); p = g.Next(p) {
+		end := g.Next(p).AddDate(0, 0, -1)
+		until := end
+		if today.Before(until) {
+			until = today // the current period only owes its days so far
+		}
+		periods = append(periods, Period{
+			Start:  p,
+			End:    end,
+			Counts: Window(habit, start, p, until, counts[p]),
+		})
+	}
+	return periods
+}
+
+// Merge adds up the periods of several habits, which must share a granularity
+func Merge(g models.Granularity, perHabit [][]Period) []Period {
+	byStart := make(map[time.Time]*Period)
+	var first, last time.Time
+	for _, periods := range perHabit {
+		for _, p := range periods {
+			if merged, ok := byStart[p.Start]; ok {
+				merged.Counts = merged.Counts.add(p.Counts)
+				continue
+			}
+			byStart[p.Start] = &Period{Start: p.Start, End: p.End, Counts: p.Counts}
+			if first.IsZero() || p.Start.Before(first) {
+				first = p.Start
+			}
+			if p.Start.After(last) {
+				last = p.Start
+			}
+		}
+	}
+
+	merged := []Period{}
+	if first.IsZero() {
+		return merged
+	}
+	// Walk every period so gaps between habits still show up, empty
+	for p := first; !p.After(last); p = g.Next(p) {
+		if m, ok := byStart[p]; ok {
+			merged = append(merged, *m)
+		} else {
+			merged = append(merged, Period{Start: p, End: g.Next(p).AddDate(0, 0, -1)})
+		}
+	}
+	return merged
+}
+
+// Sum adds up counts, such as the same rolling window of several habits
+func Sum(counts ...Counts) Counts {
+	var total Counts
+	for _, c := range counts {
+		total = total.add(c)
+	}
+	return total
+}
+
+// BestAndWorst returns the periods with the highest and lowest rate among those
+// that have ended before today and had something due, or nil if there are none.
+// Ties go to the most recent period.
+func BestAndWorst(periods []Period, today time.Time) (best, worst *Period) {
+	for i := range periods {
+		p := &periods[i]
+		if !p.End.Before(today) || p.Due == 0 {
+			continue
+		}
+		if best == nil || p.Rate() >= best.Rate() {
+			best = p
+		}
+		if worst == nil || p.Rate() <= worst.Rate() {
+			worst = p
+		}
+	}
+	return best, worst
+}
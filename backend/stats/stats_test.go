@@ -0,0 +1,174 @@
+package stats
+
+import (
+	"habit-tracker/backend/models"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestDue(t *testing.T) {
+	monThu := models.Schedule{Type: models.ScheduleWeekdays, Weekdays: []time.Weekday{time.Monday, time.Thursday}}
+	threePerWeek := models.Schedule{Type: models.ScheduleTimesPerWeek, Times: 3}
+	tenPerMonth := models.Schedule{Type: models.ScheduleTimesPerMonth, Times: 10}
+	everyThree := models.Schedule{Type: models.ScheduleEveryNDays, Interval: 3}
+
+	// 2026-10-12 is a Monday
+	tests := []struct {
+		name     string
+		schedule models.Schedule
+		from, to string
+		want     int
+	}{
+		{"daily", models.DailySchedule(), "2026-10-12", "2026-10-18", 7},
+		{"weekdays", monThu, "2026-10-11", "2026-10-17", 2},
+		{"weekdays without a covered day", monThu, "2026-10-13", "2026-10-14", 0},
+		{"times per week, whole week", threePerWeek, "2026-10-12", "2026-10-18", 3},
+		{"times per week, 3 days round down", threePerWeek, "2026-10-12", "2026-10-14", 1},
+		{"times per week, 4 days round up", threePerWeek, "2026-10-12", "2026-10-15", 2},
+		{"times per month, whole month", tenPerMonth, "2026-10-01", "2026-10-31", 10},
+		{"times per month, half a month", tenPerMonth, "2026-10-01", "2026-10-15", 5},
+		{"times per month across months of different length", tenPerMonth, "2026-02-15", "2026-03-16", 10},
+		{"every n days", everyThree, "2026-10-01", "2026-10-10", 3},
+		{"empty range", models.DailySchedule(), "2026-10-12", "2026-10-11", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Due(tt.schedule, day(tt.from), day(tt.to)); got != tt.want {
+				t.Errorf("Due = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindow(t *testing.T) {
+	monThu := models.Schedule{Type: models.ScheduleWeekdays, Weekdays: []time.Weekday{time.Monday, time.Thursday}}
+
+	tests := []struct {
+		name    string
+		habit   models.Habit
+		start   string
+		counted int
+		want    Counts
+	}{
+		{"daily", models.Habit{Kind: models.HabitBuild, Schedule: models.DailySchedule()}, "2026-10-01", 5, Counts{5, 7}},
+		{"started mid-window", models.Habit{Kind: models.HabitBuild, Schedule: models.DailySchedule()}, "2026-10-15", 2, Counts{2, 3}},
+		{"weekdays", models.Habit{Kind: models.HabitBuild, Schedule: monThu}, "2026-10-01", 1, Counts{1, 2}},
+		{"more than due", models.Habit{Kind: models.HabitBuild, Schedule: monThu}, "2026-10-01", 3, Counts{2, 2}},
+		{"quit", models.Habit{Kind: models.HabitQuit, Schedule: models.DailySchedule()}, "2026-10-13", 1, Counts{4, 5}},
+		{"started after the window", models.Habit{Kind: models.HabitBuild, Schedule: models.DailySchedule()}, "2026-10-20", 0, Counts{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Window(&tt.habit, day(tt.start), day("2026-10-11"), day("2026-10-17"), tt.counted)
+			if got != tt.want {
+				t.Errorf("Window = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	g := models.GranularityWeek
+	week := func(s string, completed, due int) Period {
+		start := day(s)
+		return Period{Start: start, End: g.Next(start).AddDate(0, 0, -1), Counts: Counts{completed, due}}
+	}
+
+	tests := []struct {
+		name     string
+		perHabit [][]Period
+		want     []Period
+	}{
+		{"nothing", nil, []Period{}},
+		{"one habit", [][]Period{{week("2026-10-05", 1, 2)}}, []Period{week("2026-10-05", 1, 2)}},
+		{
+			name: "overlapping with a gap",
+			perHabit: [][]Period{
+				{week("2026-09-28", 1, 2), week("2026-10-05", 2, 2)},
+				{week("2026-10-05", 1, 3), week("2026-10-19", 0, 1)},
+			},
+			want: []Period{week("2026-09-28", 1, 2), week("2026-10-05", 3, 5), week("2026-10-12", 0, 0), week("2026-10-19", 0, 1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Merge(g, tt.perHabit)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Merge = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Start.Equal(tt.want[i].Start) || !got[i].End.Equal(tt.want[i].End) || got[i].Counts != tt.want[i].Counts {
+					t.Errorf("period %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBestAndWorst(t *testing.T) {
+	today := day("2026-10-17")
+	period := func(start, end string, completed, due int) Period {
+		return Period{Start: day(start), End: day(end), Counts: Counts{completed, due}}
+	}
+
+	tests := []struct {
+		name        string
+		periods     []Period
+		best, worst string // start of the period, empty for none
+	}{
+		{"nothing", nil, "", ""},
+		{
+			name: "ties go to the most recent",
+			periods: []Period{
+				period("2026-09-21", "2026-09-27", 1, 2),
+				period("2026-09-28", "2026-10-04", 2, 2),
+				period("2026-10-05", "2026-10-11", 1, 2),
+				period("2026-10-12", "2026-10-18", 2, 2), // still running
+			},
+			best: "2026-09-28", worst: "2026-10-05",
+		},
+		{
+			name: "nothing due or not over",
+			periods: []Period{
+				period("2026-10-05", "2026-10-11", 0, 0),
+				period("2026-10-12", "2026-10-18", 1, 1),
+			},
+		},
+		{
+			name:    "ended yesterday",
+			periods: []Period{period("2026-10-10", "2026-10-16", 3, 4)},
+			best:    "2026-10-10", worst: "2026-10-10",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			best, worst := BestAndWorst(tt.periods, today)
+			if got := periodStart(best); got != tt.best {
+				t.Errorf("best = %s, want %s", got, tt.best)
+			}
+			if got := periodStart(worst); got != tt.worst {
+				t.Errorf("worst = %s, want %s", got, tt.worst)
+			}
+		})
+	}
+}
+
+// periodStart formats the start of p, or is empty for nil
+func periodStart(p *Period) string {
+	if p == nil {
+		return ""
+	}
+	return p.Start.Format("2006-01-02")
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// Dialect identifies the SQL database behind a Store
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// sqlitePragmas are applied to every SQLite connection: enforce the
+// ON DELETE CASCADE foreign keys, allow concurrent readers and wait on
+// locks instead of failing immediately.
+const sqlitePragmas = "_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+
+// Open connects to the database named by dsn.
+//
+// A DSN starting with sqlite:// selects the embedded SQLite driver, e.g.
+// sqlite:///var/lib/habits.db for an absolute path or sqlite://habits.db
+// for one relative to the working directory. Anything else is handed to
+// the PostgreSQL driver.
+func Open(dsn string) (*sql.DB, Dialect, error) {
+	if path, ok := strings.CutPrefix(dsn, "sqlite://"); ok {
+		if path == "" {
+			return nil, "", fmt.Errorf("sqlite DSN %q has no file path", dsn)
+		}
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = "&"
+		}
+		db, err := sql.Open("sqlite", "file:"+path+sep+sqlitePragmas)
+		if err != nil {
+			return nil, "", err
+		}
+		// SQLite allows a single writer; serialising through one connection avoids SQLITE_BUSY
+		db.SetMaxOpenConns(1)
+		return db, SQLite, nil
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, "", err
+	}
+	return db, Postgres, nil
+}
+
+// New returns the Store implementation for an open connection of the given dialect
+func New(db *sql.DB, dialect Dialect) Store {
+	if dialect == SQLite {
+		return NewSQLiteStore(db)
+	}
+	return NewPostgresStore(db)
+}
@@ -0,0 +1,570 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"habit-tracker/backend/models"
+	"time"
+)
+
+var _ Store = (*SQLStore)(nil)
+
+// SQLStore implements Store on top of a database/sql connection.
+//
+// The queries are written so that PostgreSQL and SQLite (3.35+, for
+// RETURNING and ON CONFLICT) both accept them; dialect is kept for the
+// few places where the two differ.
+type SQLStore struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+// NewPostgresStore wraps an open PostgreSQL connection
+func NewPostgresStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db, dialect: Postgres}
+}
+
+// NewSQLiteStore wraps an open SQLite connection
+func NewSQLiteStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db, dialect: SQLite}
+}
+
+func (s *SQLStore) CreateUser(ctx context.Context, user *models.User) error {
+	query := `INSERT INTO users(username, email, password, time_zone, email_verified, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	return s.db.QueryRowContext(ctx, query, user.Username, user.Email, user.Password, user.TimeZone, user.EmailVerified, user.CreatedAt).Scan(&user.ID)
+}
+
+const userColumns = `id, username, email, password, time_zone, email_verified, created_at`
+
+func scanUser(row scanner, user *models.User) error {
+	return row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.TimeZone, &user.EmailVerified, &user.CreatedAt)
+}
+
+func (s *SQLStore) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := scanUser(s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE email=$1", email), &user); err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (s *SQLStore) GetUserByID(ctx context.Context, userID int) (*models.User, error) {
+	var user models.User
+	if err := scanUser(s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id=$1", userID), &user); err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (s *SQLStore) ListUsers(ctx context.Context) ([]models.User, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+userColumns+" FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (s *SQLStore) UpdateUser(ctx context.Context, user *models.User) error {
+	query := `UPDATE users SET username=$1, time_zone=$2 WHERE id=$3 RETURNING ` + userColumns
+	return notFound(scanUser(s.db.QueryRowContext(ctx, query, user.Username, user.TimeZone, user.ID), user))
+}
+
+func (s *SQLStore) UpdatePassword(ctx context.Context, userID int, passwordHash string) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE users SET password=$1 WHERE id=$2`, passwordHash, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLStore) SetEmailVerified(ctx context.Context, userID int, verified bool) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE users SET email_verified=$1 WHERE id=$2`, verified, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLStore) UpdateEmail(ctx context.Context, userID int, email string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var taken bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE email=$1 AND id<>$2)`, email, userID).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrConflict
+	}
+
+	res, err := tx.ExecContext(ctx, `UPDATE users SET email=$1, email_verified=$2 WHERE id=$3`, email, true, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) DeleteUser(ctx context.Context, userID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The foreign keys cascade too, but deleting the habit data explicitly keeps
+	// this correct on databases where they are not enforced
+	for _, table := range []string{"habit_streaks", "habit_completions", "habits"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id=$1`, userID); err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id=$1`, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// habitColumns lists the habit columns in the order scanHabit expects them
+const habitColumns = `id, user_id, title, description, kind,
+	habit_type, target, unit, comparison,
+	schedule_type, schedule_weekdays, schedule_times, schedule_interval,
+	created_at, updated_at`
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanHabit(row scanner, habit *models.Habit) error {
+	var weekdays int
+	err := row.Scan(&habit.ID, &habit.UserID, &habit.Title, &habit.Description, &habit.Kind,
+		&habit.Type, &habit.Target, &habit.Unit, &habit.Comparison,
+		&habit.Schedule.Type, &weekdays, &habit.Schedule.Times, &habit.Schedule.Interval,
+		&habit.CreatedAt, &habit.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	habit.Schedule.SetWeekdayMask(weekdays)
+	return nil
+}
+
+func (s *SQLStore) ListHabits(ctx context.Context, userID int) ([]models.Habit, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+habitColumns+" FROM habits WHERE user_id=$1", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var habits []models.Habit
+	for rows.Next() {
+		var habit models.Habit
+		if err := scanHabit(rows, &habit); err != nil {
+			return nil, err
+		}
+		habits = append(habits, habit)
+	}
+	return habits, rows.Err()
+}
+
+func (s *SQLStore) GetHabit(ctx context.Context, habitID, userID int) (*models.Habit, error) {
+	var habit models.Habit
+	query := "SELECT " + habitColumns + " FROM habits WHERE id = $1 AND user_id = $2"
+	if err := scanHabit(s.db.QueryRowContext(ctx, query, habitID, userID), &habit); err != nil {
+		return nil, notFound(err)
+	}
+	return &habit, nil
+}
+
+func (s *SQLStore) CreateHabit(ctx context.Context, habit *models.Habit) error {
+	query := `
+		INSERT INTO habits(user_id, title, description, kind,
+			habit_type, target, unit, comparison,
+			schedule_type, schedule_weekdays, schedule_times, schedule_interval,
+			created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id
+	`
+	sch := habit.Schedule
+	return s.db.QueryRowContext(ctx, query, habit.UserID, habit.Title, habit.Description, habit.Kind,
+		habit.Type, habit.Target, habit.Unit, habit.Comparison,
+		sch.Type, sch.WeekdayMask(), sch.Times, sch.Interval,
+		habit.CreatedAt, habit.UpdatedAt).Scan(&habit.ID)
+}
+
+func (s *SQLStore) UpdateHabit(ctx context.Context, habit *models.Habit) error {
+	query := `
+		UPDATE habits
+		SET title=$1, description=$2, kind=$3,
+			habit_type=$4, target=$5, unit=$6, comparison=$7,
+			schedule_type=$8, schedule_weekdays=$9, schedule_times=$10, schedule_interval=$11,
+			updated_at=$12
+		WHERE id=$13 AND user_id=$14
+		RETURNING ` + habitColumns
+	sch := habit.Schedule
+	row := s.db.QueryRowContext(ctx, query, habit.Title, habit.Description, habit.Kind,
+		habit.Type, habit.Target, habit.Unit, habit.Comparison,
+		sch.Type, sch.WeekdayMask(), sch.Times, sch.Interval,
+		habit.UpdatedAt, habit.ID, habit.UserID)
+	return notFound(scanHabit(row, habit))
+}
+
+func (s *SQLStore) DeleteHabit(ctx context.Context, habitID, userID int) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM habits WHERE id=$1 AND user_id=$2`, habitID, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLStore) CountHabits(ctx context.Context, userID int) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM habits WHERE user_id=$1`, userID).Scan(&count)
+	return count, err
+}
+
+func (s *SQLStore) AddCompletion(ctx context.Context, habitID, userID int, date, completedAt time.Time) (bool, error) {
+	query := `
+		INSERT INTO habit_completions (habit_id, user_id, date_completed, completed_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (habit_id, date_completed) DO NOTHING
+		RETURNING id
+	`
+	var completionID int
+	err := s.db.QueryRowContext(ctx, query, habitID, userID, date.Format("2006-01-02"), completedAt.UTC()).Scan(&completionID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *SQLStore) AccumulateCompletion(ctx context.Context, habitID, userID int, date time.Time, value float64, completedAt time.Time) (float64, error) {
+	query := `
+		INSERT INTO habit_completions (habit_id, user_id, date_completed, value, completed_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (habit_id, date_completed)
+		DO UPDATE SET value = habit_completions.value + EXCLUDED.value, completed_at = EXCLUDED.completed_at
+		RETURNING value
+	`
+	var total float64
+	err := s.db.QueryRowContext(ctx, query, habitID, userID, date.Format("2006-01-02"), value, completedAt.UTC()).Scan(&total)
+	return total, err
+}
+
+func (s *SQLStore) AddCompletions(ctx context.Context, userID int, entries []models.CompletionEntry) ([]bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO habit_completions (habit_id, user_id, date_completed, value, note)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (habit_id, date_completed) DO NOTHING
+		RETURNING id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	inserted := make([]bool, len(entries))
+	for i, e := range entries {
+		var completionID int
+		err := stmt.QueryRowContext(ctx, e.HabitID, userID, e.Date.Format("2006-01-02"), e.Value, e.Note).Scan(&completionID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		inserted[i] = true
+	}
+	return inserted, tx.Commit()
+}
+
+func (s *SQLStore) UpdateCompletion(ctx context.Context, habitID, userID int, date, newDate time.Time, value float64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	from, to := date.Format("2006-01-02"), newDate.Format("2006-01-02")
+	if to != from {
+		var exists bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM habit_completions WHERE habit_id = $1 AND user_id = $2 AND date_completed = $3)
+		`, habitID, userID, to).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrConflict
+		}
+	}
+
+	// The check-in time was on the old day; when the habit was really done on the
+	// new one is unknown, so the hour patterns leave it out
+	set := `date_completed = $1, value = $2`
+	if to != from {
+		set += `, completed_at = NULL`
+	}
+	res, err := tx.ExecContext(ctx, `
+		UPDATE habit_completions SET `+set+`
+		WHERE habit_id = $3 AND user_id = $4 AND date_completed = $5
+	`, to, value, habitID, userID, from)
+	if err != nil {
+		return err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) DeleteCompletion(ctx context.Context, habitID, userID int, date time.Time) error {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM habit_completions WHERE habit_id = $1 AND user_id = $2 AND date_completed = $3
+	`, habitID, userID, date.Format("2006-01-02"))
+	if err != nil {
+		return err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLStore) DailyValues(ctx context.Context, habitID, userID int) ([]models.DailyValue, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT date_completed, value, note
+		FROM habit_completions
+		WHERE habit_id = $1 AND user_id = $2
+		ORDER BY date_completed ASC
+	`, habitID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []models.DailyValue
+	for rows.Next() {
+		var dv models.DailyValue
+		if err := rows.Scan(&dv.Date, &dv.Value, &dv.Note); err != nil {
+			return nil, err
+		}
+		values = append(values, dv)
+	}
+	return values, rows.Err()
+}
+
+func (s *SQLStore) ListCompletedHabits(ctx context.Context, userID int) ([]models.CompletedHabit, error) {
+	query := `
+		SELECT hc.id, hc.habit_id, hc.date_completed, h.title, h.description
+		FROM habit_completions hc
+		JOIN habits h ON hc.habit_id = h.id
+		WHERE hc.user_id = $1 AND h.kind = $2
+		ORDER BY hc.date_completed DESC
+	`
+	rows, err := s.db.QueryContext(ctx, query, userID, models.HabitBuild)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var completed []models.CompletedHabit
+	for rows.Next() {
+		ch := models.CompletedHabit{Completion: models.Completion{UserID: userID}}
+		if err := rows.Scan(&ch.ID, &ch.HabitID, &ch.DateCompleted, &ch.Title, &ch.Description); err != nil {
+			return nil, err
+		}
+		completed = append(completed, ch)
+	}
+	return completed, rows.Err()
+}
+
+func (s *SQLStore) CountCompletions(ctx context.Context, userID int, kind models.HabitKind) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM habit_completions hc
+		JOIN habits h ON hc.habit_id = h.id
+		WHERE hc.user_id=$1 AND h.kind=$2
+	`, userID, kind).Scan(&count)
+	return count, err
+}
+
+func (s *SQLStore) SaveStreak(ctx context.Context, streak models.Streak) error {
+	query := `
+		INSERT INTO habit_streaks (habit_id, user_id, current_streak, longest_streak, last_completed, strength)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (habit_id, user_id)
+		DO UPDATE SET
+			current_streak = EXCLUDED.current_streak,
+			longest_streak = EXCLUDED.longest_streak,
+			last_completed = EXCLUDED.last_completed,
+			strength = EXCLUDED.strength
+	`
+	var lastCompleted any
+	if streak.LastCompleted != nil {
+		lastCompleted = streak.LastCompleted.Format("2006-01-02")
+	}
+	_, err := s.db.ExecContext(ctx, query, streak.HabitID, streak.UserID, streak.CurrentStreak, streak.LongestStreak, lastCompleted, streak.Strength)
+	return err
+}
+
+func (s *SQLStore) DeleteStreak(ctx context.Context, habitID, userID int) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM habit_strength WHERE habit_id = $1 AND user_id = $2`, habitID, userID); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `DELETE FROM habit_streaks WHERE habit_id = $1 AND user_id = $2`, habitID, userID)
+	return err
+}
+
+func (s *SQLStore) ListHabitStreaks(ctx context.Context, userID int) ([]models.HabitWithStreak, error) {
+	query := `
+		SELECT h.id, h.title, h.description, h.kind,
+		       s.current_streak, s.longest_streak, s.last_completed, s.strength
+		FROM habits h
+		LEFT JOIN habit_streaks s ON h.id = s.habit_id
+		WHERE h.user_id = $1
+	`
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.HabitWithStreak
+	for rows.Next() {
+		habit := models.Habit{UserID: userID}
+		var currentStreak, longestStreak sql.NullInt64
+		var lastCompleted sql.NullTime
+		var strength sql.NullFloat64
+		if err := rows.Scan(&habit.ID, &habit.Title, &habit.Description, &habit.Kind, &currentStreak, &longestStreak, &lastCompleted, &strength); err != nil {
+			return nil, err
+		}
+
+		result := models.HabitWithStreak{Habit: habit}
+		if currentStreak.Valid {
+			result.Streak = &models.Streak{
+				HabitID:       habit.ID,
+				UserID:        userID,
+				CurrentStreak: int(currentStreak.Int64),
+				LongestStreak: int(longestStreak.Int64),
+				Strength:      strength.Float64,
+			}
+			if lastCompleted.Valid {
+				result.Streak.LastCompleted = &lastCompleted.Time
+			}
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func (s *SQLStore) LongestStreak(ctx context.Context, userID int) (int, error) {
+	var longest int
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(longest_streak), 0) FROM habit_streaks WHERE user_id=$1`, userID).Scan(&longest)
+	return longest, err
+}
+
+func (s *SQLStore) MostConsistentHabit(ctx context.Context, userID int) (string, error) {
+	var title sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT h.title
+		FROM habit_streaks s
+		JOIN habits h ON s.habit_id = h.id
+		WHERE s.user_id=$1
+		ORDER BY s.strength DESC, s.current_streak DESC
+		LIMIT 1
+	`, userID).Scan(&title)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return title.String, nil
+}
+
+// notFound maps sql.ErrNoRows onto ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *SQLStore) ClaimStreakRecompute(ctx context.Context, userID int, day, now, leaseUntil time.Time) (bool, error) {
+	// A row without a lease is finished; one whose lease ran out is taken over
+	query := `
+		INSERT INTO streak_recomputes (user_id, day, claimed_at, leased_until)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, day) DO UPDATE SET claimed_at = EXCLUDED.claimed_at, leased_until = EXCLUDED.leased_until
+		WHERE streak_recomputes.leased_until IS NOT NULL AND streak_recomputes.leased_until < $3
+		RETURNING user_id
+	`
+	var claimed int
+	err := s.db.QueryRowContext(ctx, query, userID, day.Format("2006-01-02"), now.UTC(), leaseUntil.UTC()).Scan(&claimed)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Older claims are never looked at again
+	_, err = s.db.ExecContext(ctx, `DELETE FROM streak_recomputes WHERE user_id = $1 AND day < $2`,
+		userID, day.AddDate(0, 0, -7).Format("2006-01-02"))
+	return true, err
+}
+
+func (s *SQLStore) FinishStreakRecompute(ctx context.Context, userID int, day time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE streak_recomputes SET leased_until = NULL WHERE user_id = $1 AND day = $2`,
+		userID, day.Format("2006-01-02"))
+	return err
+}
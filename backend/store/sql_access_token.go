@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"habit-tracker/backend/models"
+	"strings"
+	"time"
+)
+
+const accessTokenColumns = `id, user_id, name, scopes, token_hash, prefix, created_at, last_used_at, expires_at`
+
+func scanAccessToken(row scanner, token *models.AccessToken) error {
+	var scopes string
+	var lastUsedAt, expiresAt sql.NullTime
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &scopes, &token.TokenHash, &token.Prefix,
+		&token.CreatedAt, &lastUsedAt, &expiresAt)
+	if err != nil {
+		return err
+	}
+	token.Scopes = strings.Split(scopes, ",")
+	token.LastUsedAt, token.ExpiresAt = nil, nil
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	return nil
+}
+
+func (s *SQLStore) CreateAccessToken(ctx context.Context, token *models.AccessToken) error {
+	query := `
+		INSERT INTO access_tokens (user_id, name, scopes, token_hash, prefix, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+	var expiresAt any
+	if token.ExpiresAt != nil {
+		expiresAt = token.ExpiresAt.UTC()
+	}
+	return s.db.QueryRowContext(ctx, query, token.UserID, token.Name, strings.Join(token.Scopes, ","),
+		token.TokenHash, token.Prefix, token.CreatedAt.UTC(), expiresAt).Scan(&token.ID)
+}
+
+func (s *SQLStore) ListAccessTokens(ctx context.Context, userID int) ([]models.AccessToken, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+accessTokenColumns+" FROM access_tokens WHERE user_id = $1 ORDER BY id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.AccessToken{}
+	for rows.Next() {
+		var token models.AccessToken
+		if err := scanAccessToken(rows, &token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *SQLStore) GetAccessTokenByHash(ctx context.Context, tokenHash string) (*models.AccessToken, error) {
+	var token models.AccessToken
+	row := s.db.QueryRowContext(ctx, "SELECT "+accessTokenColumns+" FROM access_tokens WHERE token_hash = $1", tokenHash)
+	if err := scanAccessToken(row, &token); err != nil {
+		return nil, notFound(err)
+	}
+	return &token, nil
+}
+
+func (s *SQLStore) DeleteAccessToken(ctx context.Context, tokenID, userID int) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM access_tokens WHERE id = $1 AND user_id = $2`, tokenID, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLStore) DeleteUserAccessTokens(ctx context.Context, userID int) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM access_tokens WHERE user_id = $1`, userID)
+	return err
+}
+
+func (s *SQLStore) TouchAccessToken(ctx context.Context, tokenID int, usedAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE access_tokens SET last_used_at = $1 WHERE id = $2`, usedAt.UTC(), tokenID)
+	return err
+}
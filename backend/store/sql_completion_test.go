@@ -0,0 +1,50 @@
+package store_test
+
+import (
+	"context"
+	"habit-tracker/backend/models"
+	"testing"
+	"time"
+)
+
+func TestUpdateCompletionMoveClearsCheckInTime(t *testing.T) {
+	ctx := context.Background()
+	s := openSQLite(t)
+
+	user := models.User{Username: "ann", Email: "ann@example.com", Password: "x", TimeZone: "UTC", CreatedAt: time.Now()}
+	if err := s.CreateUser(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+	habit := models.Habit{UserID: user.ID, Title: "Read", Schedule: models.DailySchedule(), CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	habit.Normalize()
+	if err := s.CreateHabit(ctx, &habit); err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	checkedIn := time.Date(2026, 10, 16, 7, 30, 0, 0, time.UTC)
+	for _, d := range []string{"2026-10-15", "2026-10-16"} {
+		if _, err := s.AddCompletion(ctx, habit.ID, user.ID, day(d), checkedIn); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Correcting the value keeps the check-in, moving the day drops it
+	if err := s.UpdateCompletion(ctx, habit.ID, user.ID, day("2026-10-15"), day("2026-10-15"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateCompletion(ctx, habit.ID, user.ID, day("2026-10-16"), day("2026-10-12"), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	checkIns, err := s.CheckIns(ctx, user.ID, habit.ID, day("2026-10-01"), day("2026-10-31"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checkIns) != 1 || !checkIns[0].Date.Equal(day("2026-10-15")) || !checkIns[0].CompletedAt.Equal(checkedIn) {
+		t.Errorf("check-ins = %+v, want only the one on 2026-10-15", checkIns)
+	}
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"habit-tracker/backend/models"
+	"time"
+)
+
+func (s *SQLStore) GetUserByIdentity(ctx context.Context, issuer, subject string) (*models.User, error) {
+	query := `
+		SELECT ` + userColumns + ` FROM users
+		WHERE id = (SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2)
+	`
+	var user models.User
+	if err := scanUser(s.db.QueryRowContext(ctx, query, issuer, subject), &user); err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (s *SQLStore) LinkIdentity(ctx context.Context, userID int, issuer, subject string) error {
+	return linkIdentity(ctx, s.db, userID, issuer, subject)
+}
+
+func (s *SQLStore) CreateUserWithIdentity(ctx context.Context, user *models.User, issuer, subject string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var taken bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE email=$1)`, user.Email).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrConflict
+	}
+
+	query := `INSERT INTO users(username, email, password, time_zone, email_verified, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	err = tx.QueryRowContext(ctx, query, user.Username, user.Email, user.Password, user.TimeZone, user.EmailVerified, user.CreatedAt.UTC()).Scan(&user.ID)
+	if err != nil {
+		return err
+	}
+	if err := linkIdentity(ctx, tx, user.ID, issuer, subject); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// linkIdentity inserts the link unless the identity already belongs to someone
+func linkIdentity(ctx context.Context, q querier, userID int, issuer, subject string) error {
+	query := `
+		INSERT INTO user_identities (user_id, issuer, subject, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (issuer, subject) DO NOTHING
+	`
+	res, err := q.ExecContext(ctx, query, userID, issuer, subject, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrConflict
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"habit-tracker/backend/models"
+	"time"
+)
+
+const sessionColumns = `id, user_id, family_id, token_hash, access_jti, access_expires_at, created_at, expires_at, revoked_at`
+
+func scanSession(row scanner, session *models.Session) error {
+	var revokedAt sql.NullTime
+	err := row.Scan(&session.ID, &session.UserID, &session.FamilyID, &session.TokenHash,
+		&session.AccessJTI, &session.AccessExpiresAt, &session.CreatedAt, &session.ExpiresAt, &revokedAt)
+	if err != nil {
+		return err
+	}
+	session.RevokedAt = nil
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return nil
+}
+
+// querier is implemented by *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func insertSession(ctx context.Context, db querier, session *models.Session) error {
+	query := `
+		INSERT INTO sessions (user_id, family_id, token_hash, access_jti, access_expires_at, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+	return db.QueryRowContext(ctx, query, session.UserID, session.FamilyID, session.TokenHash, session.AccessJTI,
+		session.AccessExpiresAt.UTC(), session.CreatedAt.UTC(), session.ExpiresAt.UTC()).Scan(&session.ID)
+}
+
+func (s *SQLStore) CreateSession(ctx context.Context, session *models.Session) error {
+	return insertSession(ctx, s.db, session)
+}
+
+func (s *SQLStore) GetSessionByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error) {
+	var session models.Session
+	row := s.db.QueryRowContext(ctx, "SELECT "+sessionColumns+" FROM sessions WHERE token_hash = $1", tokenHash)
+	if err := scanSession(row, &session); err != nil {
+		return nil, notFound(err)
+	}
+	return &session, nil
+}
+
+func (s *SQLStore) RotateSession(ctx context.Context, oldID int, next *models.Session) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	revoked, err := revokeSessions(ctx, tx, "id = $2", oldID)
+	if err != nil {
+		return err
+	}
+	// Someone else rotated it first
+	if revoked == 0 {
+		return ErrConflict
+	}
+	if err := insertSession(ctx, tx, next); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) RevokeSession(ctx context.Context, sessionID, userID int) error {
+	return s.revokeInTx(ctx, "id = $2 AND user_id = $3", sessionID, userID)
+}
+
+func (s *SQLStore) RevokeSessionFamily(ctx context.Context, familyID string) error {
+	return s.revokeInTx(ctx, "family_id = $2", familyID)
+}
+
+func (s *SQLStore) RevokeUserSessions(ctx context.Context, userID int) error {
+	return s.revokeInTx(ctx, "user_id = $2", userID)
+}
+
+func (s *SQLStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`, jti).Scan(&revoked)
+	return revoked, err
+}
+
+func (s *SQLStore) revokeInTx(ctx context.Context, where string, args ...any) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := revokeSessions(ctx, tx, where, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// revokeSessions revokes the active sessions matching where, whose placeholders
+// start at $2, and puts their unexpired access tokens on the revocation list
+func revokeSessions(ctx context.Context, tx *sql.Tx, where string, args ...any) (int64, error) {
+	now := time.Now().UTC()
+	args = append([]any{now}, args...)
+
+	// Revocations of expired tokens are no longer needed
+	if _, err := tx.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at < $1`, now); err != nil {
+		return 0, err
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO revoked_tokens (jti, expires_at)
+		SELECT access_jti, access_expires_at FROM sessions
+		WHERE `+where+` AND revoked_at IS NULL AND access_expires_at > $1
+		ON CONFLICT (jti) DO NOTHING
+	`, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, `UPDATE sessions SET revoked_at = $1 WHERE `+where+` AND revoked_at IS NULL`, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
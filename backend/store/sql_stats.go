@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"habit-tracker/backend/models"
+	"time"
+)
+
+// metTarget is true for completions whose total meets the habit's target, the SQL
+// counterpart of models.Habit.MeetsTarget
+const metTarget = `(h.habit_type <> 'numeric'
+	OR (h.comparison = 'at_most' AND hc.value <= h.target)
+	OR (h.comparison <> 'at_most' AND hc.value >= h.target))`
+
+// onScheduledDay is true for completions on a day the habit's schedule covers, the
+// SQL counterpart of models.Schedule.Covers. A weekdays habit done on another day
+// was not due then, so it must not make up for a due day that was missed.
+func (s *SQLStore) onScheduledDay() string {
+	weekday := `CAST(EXTRACT(DOW FROM hc.date_completed) AS INTEGER)`
+	if s.dialect == SQLite {
+		weekday = `CAST(strftime('%w', hc.date_completed) AS INTEGER)`
+	}
+	return `(h.schedule_type <> 'weekdays' OR (h.schedule_weekdays & (1 << ` + weekday + `)) <> 0)`
+}
+
+// periodStart returns an expression for the first day of the period containing
+// column, formatted YYYY-MM-DD so both drivers scan it the same way
+func (s *SQLStore) periodStart(g models.Granularity, column string) string {
+	if s.dialect == SQLite {
+		switch g {
+		case models.GranularityWeek:
+			// %w counts from Sunday; shift so weeks start on Monday like date_trunc
+			return fmt.Sprintf(`date(%[1]s, '-' || ((CAST(strftime('%%w', %[1]s) AS INTEGER) + 6) %% 7) || ' days')`, column)
+		case models.GranularityYear:
+			return fmt.Sprintf(`strftime('%%Y-01-01', %s)`, column)
+		default:
+			return fmt.Sprintf(`strftime('%%Y-%%m-01', %s)`, column)
+		}
+	}
+	// g is one of the Granularity constants, never user input
+	return fmt.Sprintf(`to_char(date_trunc('%s', %s), 'YYYY-MM-DD')`, g, column)
+}
+
+// dateText formats a DATE column as YYYY-MM-DD
+func (s *SQLStore) dateText(expr string) string {
+	if s.dialect == SQLite {
+		return `date(` + expr + `)`
+	}
+	return `to_char(` + expr + `, 'YYYY-MM-DD')`
+}
+
+func (s *SQLStore) CompletionsByPeriod(ctx context.Context, userID, habitID int, g models.Granularity) ([]models.PeriodCount, error) {
+	period := s.periodStart(g, "hc.date_completed")
+	query := `
+		SELECT hc.habit_id, ` + period + ` AS period, COUNT(*), ` + s.dateText("MIN(hc.date_completed)") + `
+		FROM habit_completions hc
+		JOIN habits h ON hc.habit_id = h.id
+		WHERE hc.user_id = $1 AND ($2 = 0 OR hc.habit_id = $2) AND ` + metTarget + `
+			AND ` + s.onScheduledDay() + `
+		GROUP BY hc.habit_id, ` + period + `
+		ORDER BY hc.habit_id, period
+	`
+	rows, err := s.db.QueryContext(ctx, query, userID, habitID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []models.PeriodCount
+	for rows.Next() {
+		var pc models.PeriodCount
+		var period, first string
+		if err := rows.Scan(&pc.HabitID, &period, &pc.Count, &first); err != nil {
+			return nil, err
+		}
+		if pc.Period, err = time.Parse("2006-01-02", period); err != nil {
+			return nil, err
+		}
+		if pc.First, err = time.Parse("2006-01-02", first); err != nil {
+			return nil, err
+		}
+		counts = append(counts, pc)
+	}
+	return counts, rows.Err()
+}
+
+func (s *SQLStore) CompletionsBetween(ctx context.Context, userID, habitID int, from, to time.Time) (map[int]int, error) {
+	query := `
+		SELECT hc.habit_id, COUNT(*)
+		FROM habit_completions hc
+		JOIN habits h ON hc.habit_id = h.id
+		WHERE hc.user_id = $1 AND ($2 = 0 OR hc.habit_id = $2)
+			AND hc.date_completed >= $3 AND hc.date_completed <= $4 AND ` + metTarget + `
+			AND ` + s.onScheduledDay() + `
+		GROUP BY hc.habit_id
+	`
+	rows, err := s.db.QueryContext(ctx, query, userID, habitID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var id, count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, err
+		}
+		counts[id] = count
+	}
+	return counts, rows.Err()
+}
+
+func (s *SQLStore) CheckIns(ctx context.Context, userID, habitID int, from, to time.Time) ([]models.CheckIn, error) {
+	query := `
+		SELECT hc.habit_id, ` + s.dateText("hc.date_completed") + `, hc.completed_at
+		FROM habit_completions hc
+		JOIN habits h ON hc.habit_id = h.id
+		WHERE hc.user_id = $1 AND ($2 = 0 OR hc.habit_id = $2) AND hc.completed_at IS NOT NULL
+			AND hc.date_completed >= $3 AND hc.date_completed <= $4 AND ` + metTarget + `
+		ORDER BY hc.date_completed
+	`
+	rows, err := s.db.QueryContext(ctx, query, userID, habitID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checkIns []models.CheckIn
+	for rows.Next() {
+		var ci models.CheckIn
+		var date string
+		if err := rows.Scan(&ci.HabitID, &date, &ci.CompletedAt); err != nil {
+			return nil, err
+		}
+		if ci.Date, err = time.Parse("2006-01-02", date); err != nil {
+			return nil, err
+		}
+		checkIns = append(checkIns, ci)
+	}
+	return checkIns, rows.Err()
+}
@@ -0,0 +1,54 @@
+package store_test
+
+import (
+	"context"
+	"habit-tracker/backend/models"
+	"testing"
+	"time"
+)
+
+func TestStatsCountOnlyScheduledDays(t *testing.T) {
+	ctx := context.Background()
+	s := openSQLite(t)
+
+	user := models.User{Username: "ann", Email: "ann@example.com", Password: "x", TimeZone: "UTC", CreatedAt: time.Now()}
+	if err := s.CreateUser(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+	monThu := models.Schedule{Type: models.ScheduleWeekdays, Weekdays: []time.Weekday{time.Monday, time.Thursday}}
+	habit := models.Habit{UserID: user.ID, Title: "Gym", Schedule: monThu, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	habit.Normalize()
+	if err := s.CreateHabit(ctx, &habit); err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	// Sunday, Monday, Tuesday and Thursday; only Monday and Thursday were due
+	var entries []models.CompletionEntry
+	for _, d := range []string{"2026-10-11", "2026-10-12", "2026-10-13", "2026-10-15"} {
+		entries = append(entries, models.CompletionEntry{HabitID: habit.ID, Date: day(d), Value: 1})
+	}
+	if _, err := s.AddCompletions(ctx, user.ID, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	between, err := s.CompletionsBetween(ctx, user.ID, habit.ID, day("2026-10-11"), day("2026-10-17"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if between[habit.ID] != 2 {
+		t.Errorf("CompletionsBetween = %d, want 2", between[habit.ID])
+	}
+
+	byWeek, err := s.CompletionsByPeriod(ctx, user.ID, habit.ID, models.GranularityWeek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sunday the 11th, the only completion in the week before, was not due
+	if len(byWeek) != 1 || !byWeek[0].Period.Equal(day("2026-10-12")) || byWeek[0].Count != 2 || !byWeek[0].First.Equal(day("2026-10-12")) {
+		t.Errorf("CompletionsByPeriod = %+v, want 2 in the week of 2026-10-12", byWeek)
+	}
+}
@@ -0,0 +1,46 @@
+package store_test
+
+import (
+	"context"
+	"habit-tracker/backend/models"
+	"testing"
+	"time"
+)
+
+func TestClaimStreakRecompute(t *testing.T) {
+	ctx := context.Background()
+	s := openSQLite(t)
+
+	user := models.User{Username: "ann", Email: "ann@example.com", Password: "x", TimeZone: "UTC", CreatedAt: time.Now()}
+	if err := s.CreateUser(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC)
+	claim := func(at time.Time, want bool) {
+		t.Helper()
+		claimed, err := s.ClaimStreakRecompute(ctx, user.ID, day, at, at.Add(10*time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claimed != want {
+			t.Fatalf("claim at %s = %v, want %v", at.Format("15:04"), claimed, want)
+		}
+	}
+
+	claim(now, true)
+	// Another replica while the lease runs
+	claim(now.Add(5*time.Minute), false)
+	// The first one died; once its lease ran out the work is taken over
+	claim(now.Add(11*time.Minute), true)
+	if err := s.FinishStreakRecompute(ctx, user.ID, day); err != nil {
+		t.Fatal(err)
+	}
+	// Finished work is never claimed again that day
+	claim(now.Add(time.Hour), false)
+
+	// The next day is claimed afresh
+	day = day.AddDate(0, 0, 1)
+	claim(now.Add(24*time.Hour), true)
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"habit-tracker/backend/models"
+	"strings"
+	"time"
+)
+
+// strengthBatch is how many days SaveStrength inserts per statement, well below
+// the bind parameter limits of both drivers
+const strengthBatch = 250
+
+func (s *SQLStore) SaveStrength(ctx context.Context, habitID, userID int, keepFrom time.Time, history []models.DayStrength) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM habit_strength WHERE habit_id = $1 AND user_id = $2 AND day < $3`,
+		habitID, userID, keepFrom.Format("2006-01-02"))
+	if err != nil {
+		return err
+	}
+
+	for len(history) > 0 {
+		batch := history[:min(strengthBatch, len(history))]
+		history = history[len(batch):]
+
+		values := make([]string, len(batch))
+		args := []any{habitID, userID}
+		for i, day := range batch {
+			values[i] = fmt.Sprintf("($1, $2, $%d, $%d)", len(args)+1, len(args)+2)
+			args = append(args, day.Date.Format("2006-01-02"), day.Strength)
+		}
+		query := `
+			INSERT INTO habit_strength (habit_id, user_id, day, strength)
+			VALUES ` + strings.Join(values, ", ") + `
+			ON CONFLICT (habit_id, day) DO UPDATE SET strength = EXCLUDED.strength
+		`
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) LatestStrengthDay(ctx context.Context, habitID, userID int) (time.Time, error) {
+	var day sql.NullString
+	err := s.db.QueryRowContext(ctx, `SELECT `+s.dateText("MAX(day)")+` FROM habit_strength WHERE habit_id = $1 AND user_id = $2`,
+		habitID, userID).Scan(&day)
+	if err != nil || !day.Valid {
+		return time.Time{}, err
+	}
+	return time.Parse("2006-01-02", day.String)
+}
+
+func (s *SQLStore) StrengthHistory(ctx context.Context, habitID, userID int, from, to time.Time) ([]models.DayStrength, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+s.dateText("day")+`, strength
+		FROM habit_strength
+		WHERE habit_id = $1 AND user_id = $2 AND day >= $3 AND day <= $4
+		ORDER BY day ASC
+	`, habitID, userID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.DayStrength{}
+	for rows.Next() {
+		var ds models.DayStrength
+		var day string
+		if err := rows.Scan(&day, &ds.Strength); err != nil {
+			return nil, err
+		}
+		if ds.Date, err = time.Parse("2006-01-02", day); err != nil {
+			return nil, err
+		}
+		history = append(history, ds)
+	}
+	return history, rows.Err()
+}
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"habit-tracker/backend/models"
+	"time"
+)
+
+func (s *SQLStore) CreateUserToken(ctx context.Context, token *models.UserToken) error {
+	query := `
+		INSERT INTO user_tokens (user_id, purpose, token_hash, email, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+	return s.db.QueryRowContext(ctx, query, token.UserID, token.Purpose, token.TokenHash, token.Email,
+		token.CreatedAt.UTC(), token.ExpiresAt.UTC()).Scan(&token.ID)
+}
+
+func (s *SQLStore) ConsumeUserToken(ctx context.Context, purpose, tokenHash string) (*models.UserToken, error) {
+	// A single UPDATE both checks and spends the token, so it cannot be used twice
+	query := `
+		UPDATE user_tokens SET used_at = $1
+		WHERE purpose = $2 AND token_hash = $3 AND used_at IS NULL AND expires_at > $1
+		RETURNING id, user_id, purpose, token_hash, email, created_at, expires_at, used_at
+	`
+	var token models.UserToken
+	var usedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, query, time.Now().UTC(), purpose, tokenHash).Scan(
+		&token.ID, &token.UserID, &token.Purpose, &token.TokenHash, &token.Email, &token.CreatedAt, &token.ExpiresAt, &usedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	return &token, nil
+}
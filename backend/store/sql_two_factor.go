@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"habit-tracker/backend/models"
+	"time"
+)
+
+func (s *SQLStore) GetTwoFactor(ctx context.Context, userID int) (*models.TwoFactor, error) {
+	var tf models.TwoFactor
+	err := s.db.QueryRowContext(ctx, `
+		SELECT user_id, secret, enabled, last_step, created_at FROM two_factor WHERE user_id = $1
+	`, userID).Scan(&tf.UserID, &tf.Secret, &tf.Enabled, &tf.LastStep, &tf.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &tf, nil
+}
+
+func (s *SQLStore) SaveTwoFactor(ctx context.Context, tf *models.TwoFactor) error {
+	query := `
+		INSERT INTO two_factor (user_id, secret, enabled, last_step, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id)
+		DO UPDATE SET
+			secret = EXCLUDED.secret,
+			enabled = EXCLUDED.enabled,
+			last_step = EXCLUDED.last_step,
+			created_at = EXCLUDED.created_at
+	`
+	_, err := s.db.ExecContext(ctx, query, tf.UserID, tf.Secret, tf.Enabled, tf.LastStep, tf.CreatedAt.UTC())
+	return err
+}
+
+func (s *SQLStore) DeleteTwoFactor(ctx context.Context, userID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM two_factor WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `UPDATE two_factor SET last_step = $1 WHERE user_id = $2 AND last_step < $1`, step, userID)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+func (s *SQLStore) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE recovery_codes SET used_at = $1
+		WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL
+	`, time.Now().UTC(), userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	return rowsAffected > 0, nil
+}
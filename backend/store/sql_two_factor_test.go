@@ -0,0 +1,64 @@
+package store_test
+
+import (
+	"context"
+	"habit-tracker/backend/migrations"
+	"habit-tracker/backend/models"
+	"habit-tracker/backend/store"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openSQLite returns a store on a fresh, fully migrated SQLite database
+func openSQLite(t *testing.T) store.Store {
+	t.Helper()
+	db, dialect, err := store.Open("sqlite://" + filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	runner, err := migrations.NewRunner(db, dialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Up(); err != nil {
+		t.Fatal(err)
+	}
+	return store.New(db, dialect)
+}
+
+func TestUseTOTPStepRefusesReplay(t *testing.T) {
+	ctx := context.Background()
+	s := openSQLite(t)
+
+	user := models.User{Username: "ann", Email: "ann@example.com", Password: "x", TimeZone: "UTC", CreatedAt: time.Now()}
+	if err := s.CreateUser(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+	tf := models.TwoFactor{UserID: user.ID, Secret: "JBSWY3DPEHPK3PXP", Enabled: true, LastStep: 100, CreatedAt: time.Now()}
+	if err := s.SaveTwoFactor(ctx, &tf); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		step int64
+		ok   bool
+	}{
+		{100, false}, // the code that confirmed enrolment
+		{99, false},  // an older code still inside the skew window
+		{101, true},
+		{101, false}, // the same code again
+		{103, true},
+		{102, false}, // a skipped code after a newer one was used
+	}
+	for _, st := range steps {
+		ok, err := s.UseTOTPStep(ctx, user.ID, st.step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != st.ok {
+			t.Errorf("UseTOTPStep(%d) = %v, want %v", st.step, ok, st.ok)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Package store defines the data access layer used by the controllers.
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"habit-tracker/backend/models"
+	"time"
+)
+
+// ErrNotFound is returned when a row does not exist or does not belong to the user
+var ErrNotFound = errors.New("store: not found")
+
+// ErrConflict is returned when a write would clash with an existing row
+var ErrConflict = errors.New("store: conflict")
+
+// HashToken is how secret tokens are stored, so a database leak does not expose them
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// UserStore reads and writes user accounts
+type UserStore interface {
+	CreateUser(ctx context.Context, user *models.User) error
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	GetUserByID(ctx context.Context, userID int) (*models.User, error)
+	// ListUsers returns every user account
+	ListUsers(ctx context.Context) ([]models.User, error)
+	// UpdateUser saves the user's editable profile fields
+	UpdateUser(ctx context.Context, user *models.User) error
+	UpdatePassword(ctx context.Context, userID int, passwordHash string) error
+	SetEmailVerified(ctx context.Context, userID int, verified bool) error
+	// UpdateEmail changes the user's address and marks it verified, returning
+	// ErrConflict if another account already uses it
+	UpdateEmail(ctx context.Context, userID int, email string) error
+	// DeleteUser removes the user together with all their habits, completions and streaks
+	DeleteUser(ctx context.Context, userID int) error
+}
+
+// UserTokenStore reads and writes the single-use tokens mailed to users
+type UserTokenStore interface {
+	CreateUserToken(ctx context.Context, token *models.UserToken) error
+	// ConsumeUserToken marks an unused, unexpired token as used and returns it,
+	// or ErrNotFound if there is no such token
+	ConsumeUserToken(ctx context.Context, purpose, tokenHash string) (*models.UserToken, error)
+}
+
+// HabitStore reads and writes habits owned by a user
+type HabitStore interface {
+	ListHabits(ctx context.Context, userID int) ([]models.Habit, error)
+	GetHabit(ctx context.Context, habitID, userID int) (*models.Habit, error)
+	CreateHabit(ctx context.Context, habit *models.Habit) error
+	UpdateHabit(ctx context.Context, habit *models.Habit) error
+	DeleteHabit(ctx context.Context, habitID, userID int) error
+	CountHabits(ctx context.Context, userID int) (int, error)
+}
+
+// CompletionStore reads and writes habit completions
+type CompletionStore interface {
+	// AddCompletion records a completion checked in at completedAt and reports false
+	// if one already exists for that date
+	AddCompletion(ctx context.Context, habitID, userID int, date, completedAt time.Time) (bool, error)
+	// AccumulateCompletion adds value to the habit's total for date and returns the new
+	// total; completedAt becomes the day's check-in time
+	AccumulateCompletion(ctx context.Context, habitID, userID int, date time.Time, value float64, completedAt time.Time) (float64, error)
+	// AddCompletions inserts all entries of a user in one transaction, skipping dates that
+	// already have a completion. inserted[i] reports whether entries[i] was new.
+	AddCompletions(ctx context.Context, userID int, entries []models.CompletionEntry) (inserted []bool, err error)
+	// UpdateCompletion replaces the value recorded for date and moves it to newDate,
+	// returning ErrConflict if newDate already has a completion. A moved completion
+	// loses its check-in time.
+	UpdateCompletion(ctx context.Context, habitID, userID int, date, newDate time.Time, value float64) error
+	// DeleteCompletion removes the completion recorded for date
+	DeleteCompletion(ctx context.Context, habitID, userID int, date time.Time) error
+	// DailyValues returns the recorded total of every day with a completion, oldest first
+	DailyValues(ctx context.Context, habitID, userID int) ([]models.DailyValue, error)
+	// ListCompletedHabits returns all completions of a user's build habits, newest first
+	ListCompletedHabits(ctx context.Context, userID int) ([]models.CompletedHabit, error)
+	// CountCompletions counts the completions, or for quit habits the slips, of a user's habits of one kind
+	CountCompletions(ctx context.Context, userID int, kind models.HabitKind) (int, error)
+}
+
+// StatsStore aggregates completions in the database. Both counts are of the days whose
+// total met the habit's target, or for quit habits the slips, on days the habit's
+// schedule covers; habitID 0 means all the user's habits.
+type StatsStore interface {
+	// CompletionsByPeriod counts per habit and period over the whole history
+	CompletionsByPeriod(ctx context.Context, userID, habitID int, granularity models.Granularity) ([]models.PeriodCount, error)
+	// CompletionsBetween counts per habit from from to to inclusive
+	CompletionsBetween(ctx context.Context, userID, habitID int, from, to time.Time) (map[int]int, error)
+	// CheckIns returns the check-in times of the completions from from to to inclusive,
+	// skipping those recorded without one
+	CheckIns(ctx context.Context, userID, habitID int, from, to time.Time) ([]models.CheckIn, error)
+}
+
+// StreakStore reads and writes the persisted streak records
+type StreakStore interface {
+	SaveStreak(ctx context.Context, streak models.Streak) error
+	// DeleteStreak removes the streak record and the strength history
+	DeleteStreak(ctx context.Context, habitID, userID int) error
+	// ListHabitStreaks returns every habit of a user with its streak, if any
+	ListHabitStreaks(ctx context.Context, userID int) ([]models.HabitWithStreak, error)
+	LongestStreak(ctx context.Context, userID int) (int, error)
+	// MostConsistentHabit returns the title of the habit with the highest strength score,
+	// the higher current streak breaking ties
+	MostConsistentHabit(ctx context.Context, userID int) (string, error)
+	// SaveStrength writes the daily strength scores in history over any stored for the
+	// same days and drops those before keepFrom
+	SaveStrength(ctx context.Context, habitID, userID int, keepFrom time.Time, history []models.DayStrength) error
+	// LatestStrengthDay returns the last day with a stored strength score, zero if there is none
+	LatestStrengthDay(ctx context.Context, habitID, userID int) (time.Time, error)
+	// StrengthHistory returns a habit's daily strength scores from from to to inclusive, oldest first
+	StrengthHistory(ctx context.Context, habitID, userID int, from, to time.Time) ([]models.DayStrength, error)
+	// ClaimStreakRecompute leases a user's streak recompute for day until leaseUntil,
+	// reporting false if it is finished or another replica holds an unexpired lease
+	ClaimStreakRecompute(ctx context.Context, userID int, day, now, leaseUntil time.Time) (bool, error)
+	// FinishStreakRecompute marks a user's streaks as recomputed for day
+	FinishStreakRecompute(ctx context.Context, userID int, day time.Time) error
+}
+
+// SessionStore reads and writes refresh-token sessions and the access-token revocation list.
+// Revoking a session also revokes the access token issued with it.
+type SessionStore interface {
+	CreateSession(ctx context.Context, session *models.Session) error
+	GetSessionByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error)
+	// RotateSession revokes the session oldID and creates next in its place, returning
+	// ErrConflict if oldID was already revoked
+	RotateSession(ctx context.Context, oldID int, next *models.Session) error
+	RevokeSession(ctx context.Context, sessionID, userID int) error
+	RevokeSessionFamily(ctx context.Context, familyID string) error
+	RevokeUserSessions(ctx context.Context, userID int) error
+	// IsTokenRevoked reports whether the access token with the given jti was revoked
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// AccessTokenStore reads and writes personal access tokens
+type AccessTokenStore interface {
+	CreateAccessToken(ctx context.Context, token *models.AccessToken) error
+	// ListAccessTokens returns the user's tokens, newest first
+	ListAccessTokens(ctx context.Context, userID int) ([]models.AccessToken, error)
+	GetAccessTokenByHash(ctx context.Context, tokenHash string) (*models.AccessToken, error)
+	DeleteAccessToken(ctx context.Context, tokenID, userID int) error
+	// DeleteUserAccessTokens removes all of the user's tokens
+	DeleteUserAccessTokens(ctx context.Context, userID int) error
+	// TouchAccessToken records that the token was used at usedAt
+	TouchAccessToken(ctx context.Context, tokenID int, usedAt time.Time) error
+}
+
+// TwoFactorStore reads and writes TOTP enrolments and recovery codes
+type TwoFactorStore interface {
+	GetTwoFactor(ctx context.Context, userID int) (*models.TwoFactor, error)
+	// SaveTwoFactor creates or replaces the user's enrolment
+	SaveTwoFactor(ctx context.Context, tf *models.TwoFactor) error
+	// DeleteTwoFactor removes the enrolment and all recovery codes
+	DeleteTwoFactor(ctx context.Context, userID int) error
+	// UseTOTPStep records a code for step as used, reporting false if that or a
+	// later step was already used
+	UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error)
+	// ReplaceRecoveryCodes discards the user's recovery codes and stores the new hashes
+	ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error
+	// UseRecoveryCode spends an unused recovery code, reporting false if there is none
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error)
+}
+
+// IdentityStore links users to their accounts at an OpenID Connect provider
+type IdentityStore interface {
+	// GetUserByIdentity returns the user the provider account signs in as
+	GetUserByIdentity(ctx context.Context, issuer, subject string) (*models.User, error)
+	// LinkIdentity lets the provider account sign in as the user, returning
+	// ErrConflict if it is already linked
+	LinkIdentity(ctx context.Context, userID int, issuer, subject string) error
+	// CreateUserWithIdentity creates a user signed up through the provider, returning
+	// ErrConflict if the email address is already taken
+	CreateUserWithIdentity(ctx context.Context, user *models.User, issuer, subject string) error
+}
+
+// Store is the full set of data access operations the backend needs
+type Store interface {
+	UserStore
+	HabitStore
+	CompletionStore
+	StatsStore
+	StreakStore
+	SessionStore
+	UserTokenStore
+	AccessTokenStore
+	TwoFactorStore
+	IdentityStore
+}
@@ -0,0 +1,122 @@
+package streaks
+
+import (
+	"habit-tracker/backend/models"
+	"time"
+)
+
+// DayStatus is how one calendar day went for a habit
+type DayStatus string
+
+const (
+	StatusDone         DayStatus = "done"
+	StatusMissed       DayStatus = "missed"
+	StatusNotScheduled DayStatus = "not-scheduled"
+	StatusSkipped      DayStatus = "skipped"
+	StatusFuture       DayStatus = "future"
+)
+
+// Calendar returns the status of every day from from to to inclusive, both already
+// passed through Day, judged with the same periods as Compute.
+//
+// Days before tracking started, which for build habits is the first completion, and
+// days a weekdays schedule does not cover are not scheduled. A day without a completion is missed if its period ended without being
+// met; in a period that was met or is still open it is skipped, as the schedule did
+// not need it. Today stays in the future until it is done. For quit habits a slip
+// is a missed day and every other day is done.
+func Calendar(in Input, from, to time.Time) []DayStatus {
+	var statuses []DayStatus
+	walkCalendar(in, from, to, func(d, start, today time.Time, done bool, p *period) {
+		statuses = append(statuses, dayStatus(in.Kind, d, start, today, done, p))
+	})
+	return statuses
+}
+
+// Due reports for every day from from to to inclusive, both already passed through
+// Day, whether the schedule asked for the habit that day: from the start of tracking
+// up to today, a day inside one of the periods Compute judges, or any day for quit
+// habits. A completion on a day a weekdays schedule does not cover leaves it not due.
+func Due(in Input, from, to time.Time) []bool {
+	var due []bool
+	walkCalendar(in, from, to, func(d, start, today time.Time, _ bool, p *period) {
+		tracked := !d.Before(start) && !d.After(today)
+		due = append(due, tracked && (in.Kind == models.HabitQuit || p != nil))
+	})
+	return due
+}
+
+// walkCalendar calls visit for every day from from to to with the start of tracking,
+// today, whether the day was done and the schedule period containing it, if any
+func walkCalendar(in Input, from, to time.Time, visit func(d, start, today time.Time, done bool, p *period)) {
+	loc := in.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	clock := in.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	today := Today(clock, loc)
+
+	days := uniqueDays(in.Dates)
+	done := make(map[time.Time]bool, len(days))
+	for _, d := range days {
+		done[d] = true
+	}
+
+	// Tracking starts where Compute starts it: quit habits when they were created,
+	// or earlier for slips logged before, others at the first completion. Until
+	// there is one only today's period is open.
+	var start time.Time
+	var ps []period
+	if in.Kind == models.HabitQuit {
+		start = Day(in.Start.In(loc))
+		if len(days) > 0 && days[0].Before(start) {
+			start = days[0]
+		}
+	} else {
+		start = today
+		if len(days) > 0 {
+			start = days[0]
+		}
+		ps = periods(in.Schedule, start, days, today)
+	}
+
+	i := 0
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		for i < len(ps) && !d.Before(ps[i].end) {
+			i++
+		}
+		var p *period
+		if i < len(ps) && !d.Before(ps[i].start) {
+			p = &ps[i]
+		}
+		visit(d, start, today, done[d], p)
+	}
+}
+
+// dayStatus judges day d, where p is the schedule period containing it, if any
+func dayStatus(kind models.HabitKind, d, start, today time.Time, done bool, p *period) DayStatus {
+	switch {
+	case d.After(today):
+		return StatusFuture
+	case d.Before(start):
+		return StatusNotScheduled
+	case kind == models.HabitQuit:
+		if done {
+			return StatusMissed
+		}
+		return StatusDone
+	case done:
+		return StatusDone
+	case p == nil:
+		return StatusNotScheduled
+	case p.satisfied():
+		return StatusSkipped
+	case !p.end.After(today):
+		return StatusMissed
+	case d.Equal(today):
+		return StatusFuture
+	}
+	return StatusSkipped
+}
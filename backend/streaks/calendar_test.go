@@ -0,0 +1,110 @@
+package streaks
+
+import (
+	"habit-tracker/backend/models"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDue(t *testing.T) {
+	monThu := models.Schedule{Type: models.ScheduleWeekdays, Weekdays: []time.Weekday{time.Monday, time.Thursday}}
+
+	// 2026-10-11 is a Sunday; "now" is Saturday the 17th
+	tests := []struct {
+		name     string
+		kind     models.HabitKind
+		schedule models.Schedule
+		start    time.Time
+		dates    []time.Time
+		want     string // one character per day from the 11th to the 18th, x for due
+	}{
+		{"daily", models.HabitBuild, models.DailySchedule(), day("2026-10-01"), days("2026-10-01"), "xxxxxxx."},
+		{"daily from the first completion", models.HabitBuild, models.DailySchedule(), day("2026-10-01"), days("2026-10-14"), "...xxxx."},
+		{"daily without completions", models.HabitBuild, models.DailySchedule(), day("2026-10-01"), nil, "......x."},
+		{"weekdays", models.HabitBuild, monThu, day("2026-10-01"), days("2026-10-01"), ".x..x..."},
+		{"weekdays ignore a completion on another day", models.HabitBuild, monThu, day("2026-10-01"), days("2026-10-01", "2026-10-13"), ".x..x..."},
+		{"times per week covers every day", models.HabitBuild, models.Schedule{Type: models.ScheduleTimesPerWeek, Times: 2}, day("2026-10-01"), days("2026-10-01"), "xxxxxxx."},
+		{"quit", models.HabitQuit, models.DailySchedule(), day("2026-10-13"), days("2026-10-15"), "..xxxxx."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			due := Due(Input{
+				Dates:    tt.dates,
+				Kind:     tt.kind,
+				Schedule: tt.schedule,
+				Start:    tt.start,
+				Location: time.UTC,
+				Clock:    FixedClock(now),
+			}, day("2026-10-11"), day("2026-10-18"))
+
+			got := make([]byte, len(due))
+			for i, d := range due {
+				got[i] = '.'
+				if d {
+					got[i] = 'x'
+				}
+			}
+			if string(got) != tt.want {
+				t.Errorf("due = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCalendarAgreesWithCompute checks the calendar starts where Compute does and
+// that its done and missed days, of those due, give the same completion rate
+func TestCalendarAgreesWithCompute(t *testing.T) {
+	monThu := models.Schedule{Type: models.ScheduleWeekdays, Weekdays: []time.Weekday{time.Monday, time.Thursday}}
+
+	// Created well before the first completion, which is where build habits start
+	tests := []struct {
+		name     string
+		kind     models.HabitKind
+		schedule models.Schedule
+		dates    []time.Time
+	}{
+		{"daily", models.HabitBuild, models.DailySchedule(), days("2026-10-08", "2026-10-09", "2026-10-12", "2026-10-17")},
+		{"daily without today", models.HabitBuild, models.DailySchedule(), days("2026-10-10", "2026-10-11", "2026-10-13")},
+		{"weekdays", models.HabitBuild, monThu, days("2026-10-06", "2026-10-08", "2026-10-15")},
+		{"quit", models.HabitQuit, models.DailySchedule(), days("2026-10-09", "2026-10-14")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := Input{
+				Dates:    tt.dates,
+				Kind:     tt.kind,
+				Schedule: tt.schedule,
+				Start:    day("2026-10-01"),
+				Location: time.UTC,
+				Clock:    FixedClock(now),
+			}
+			res := Compute(in)
+			from := day("2026-09-20")
+			statuses := Calendar(in, from, day("2026-10-17"))
+			due := Due(in, from, day("2026-10-17"))
+
+			first := -1
+			done, missed := 0, 0
+			for i, status := range statuses {
+				if status != StatusNotScheduled && first < 0 {
+					first = i
+				}
+				switch {
+				case status == StatusDone && due[i]:
+					done++
+				case status == StatusMissed:
+					missed++
+				}
+			}
+			if got := from.AddDate(0, 0, first); first < 0 || !got.Equal(res.Start) {
+				t.Errorf("calendar starts on %v, Compute on %v", got, res.Start)
+			}
+			if rate := float64(done) / float64(done+missed) * 100; math.Abs(rate-res.CompletionRate) > 1e-9 {
+				t.Errorf("calendar rate = %v, Compute rate = %v", rate, res.CompletionRate)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package streaks
+
+import "time"
+
+// Clock tells the engine what time it is, so callers can pin "now" in tests and jobs
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to the Clock interface
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock reads the real wall clock
+var SystemClock Clock = ClockFunc(time.Now)
+
+// FixedClock always reports t
+func FixedClock(t time.Time) Clock {
+	return ClockFunc(func() time.Time { return t })
+}
+
+// Day strips the time of day from t, keeping its calendar date at midnight UTC
+// so that day arithmetic is exact regardless of DST in the original zone
+func Day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Today returns the current calendar day in loc, normalised like Day
+func Today(clock Clock, loc *time.Location) time.Time {
+	return Day(clock.Now().In(loc))
+}
+
+// daysBetween returns the number of whole days from a to b, both already passed through Day
+func daysBetween(a, b time.Time) int {
+	return int(b.Sub(a).Hours() / 24)
+}
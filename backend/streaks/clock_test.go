@@ -0,0 +1,143 @@
+package streaks
+
+import (
+	"habit-tracker/backend/models"
+	"testing"
+	"time"
+	_ "time/tzdata" // the zones below must resolve on hosts without a zoneinfo database
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestTodayAroundLocalMidnight(t *testing.T) {
+	tests := []struct {
+		name string
+		zone string
+		now  time.Time
+		want string
+	}{
+		// New York springs forward at 02:00 on 2026-03-08, so that day has 23 hours
+		{"spring forward, just before midnight", "America/New_York", time.Date(2026, 3, 9, 3, 59, 0, 0, time.UTC), "2026-03-08"},
+		{"spring forward, just after midnight", "America/New_York", time.Date(2026, 3, 9, 4, 1, 0, 0, time.UTC), "2026-03-09"},
+		// and falls back at 02:00 on 2026-11-01, which has 25 hours
+		{"fall back, just before midnight", "America/New_York", time.Date(2026, 11, 2, 4, 59, 0, 0, time.UTC), "2026-11-01"},
+		{"fall back, just after midnight", "America/New_York", time.Date(2026, 11, 2, 5, 1, 0, 0, time.UTC), "2026-11-02"},
+		// Auckland is UTC+13 in October, a day ahead of UTC for most of it
+		{"east of UTC, before local midnight", "Pacific/Auckland", time.Date(2026, 10, 17, 10, 59, 0, 0, time.UTC), "2026-10-17"},
+		{"east of UTC, after local midnight", "Pacific/Auckland", time.Date(2026, 10, 17, 11, 1, 0, 0, time.UTC), "2026-10-18"},
+		// Honolulu is UTC-10 all year, a day behind UTC in the evening
+		{"west of UTC, before local midnight", "Pacific/Honolulu", time.Date(2026, 10, 18, 9, 59, 0, 0, time.UTC), "2026-10-17"},
+		{"west of UTC, after local midnight", "Pacific/Honolulu", time.Date(2026, 10, 18, 10, 1, 0, 0, time.UTC), "2026-10-18"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Today(FixedClock(tt.now), mustLoad(t, tt.zone))
+			if !got.Equal(day(tt.want)) {
+				t.Errorf("Today = %s, want %s", got.Format("2006-01-02"), tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeAcrossTimeZones(t *testing.T) {
+	tests := []struct {
+		name    string
+		zone    string
+		now     time.Time
+		kind    models.HabitKind
+		start   time.Time
+		dates   []time.Time
+		current int
+	}{
+		{
+			// The 23-hour day must still count as exactly one day
+			name:    "daily streak across spring forward",
+			zone:    "America/New_York",
+			now:     time.Date(2026, 3, 10, 3, 30, 0, 0, time.UTC), // 23:30 on the 9th locally
+			start:   day("2026-03-01"),
+			dates:   days("2026-03-07", "2026-03-08", "2026-03-09"),
+			current: 3,
+		},
+		{
+			name:    "daily streak across fall back",
+			zone:    "America/New_York",
+			now:     time.Date(2026, 11, 3, 4, 30, 0, 0, time.UTC), // 23:30 on the 2nd locally
+			start:   day("2026-10-25"),
+			dates:   days("2026-10-31", "2026-11-01", "2026-11-02"),
+			current: 3,
+		},
+		{
+			name:    "quit streak across fall back counts whole days",
+			zone:    "America/New_York",
+			now:     time.Date(2026, 11, 2, 17, 0, 0, 0, time.UTC),
+			kind:    models.HabitQuit,
+			start:   time.Date(2026, 10, 30, 16, 0, 0, 0, time.UTC), // noon on the 30th locally
+			current: 4,
+		},
+		{
+			// Already the 18th in Auckland, so yesterday's completion keeps the streak
+			// and today is still open
+			name:    "east of UTC after local midnight",
+			zone:    "Pacific/Auckland",
+			now:     time.Date(2026, 10, 17, 11, 30, 0, 0, time.UTC),
+			start:   day("2026-10-01"),
+			dates:   days("2026-10-15", "2026-10-16", "2026-10-17"),
+			current: 3,
+		},
+		{
+			// In UTC the 17th would be over and missed; in Honolulu it is still open
+			name:    "west of UTC before local midnight",
+			zone:    "Pacific/Honolulu",
+			now:     time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC),
+			start:   day("2026-10-01"),
+			dates:   days("2026-10-14", "2026-10-15", "2026-10-16"),
+			current: 3,
+		},
+		{
+			name:    "west of UTC after local midnight",
+			zone:    "Pacific/Honolulu",
+			now:     time.Date(2026, 10, 19, 10, 30, 0, 0, time.UTC), // 00:30 on the 19th locally
+			start:   day("2026-10-01"),
+			dates:   days("2026-10-14", "2026-10-15", "2026-10-16"),
+			current: 0,
+		},
+		{
+			// Created in the UTC evening, which is already the next morning in Auckland,
+			// so the clean days are the 18th and today; counting from the UTC date gives 3
+			name:    "quit habit starts on the local creation day",
+			zone:    "Pacific/Auckland",
+			now:     time.Date(2026, 10, 18, 20, 0, 0, 0, time.UTC), // 09:00 on the 19th locally
+			kind:    models.HabitQuit,
+			start:   time.Date(2026, 10, 17, 20, 0, 0, 0, time.UTC), // 09:00 on the 18th locally
+			current: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind := tt.kind
+			if kind == "" {
+				kind = models.HabitBuild
+			}
+			res := Compute(Input{
+				Dates:    tt.dates,
+				Kind:     kind,
+				Schedule: models.DailySchedule(),
+				Start:    tt.start,
+				Location: mustLoad(t, tt.zone),
+				Clock:    FixedClock(tt.now),
+			})
+			if res.Current != tt.current {
+				t.Errorf("current = %d, want %d (segments %+v)", res.Current, tt.current, res.Segments)
+			}
+		})
+	}
+}
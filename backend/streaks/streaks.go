@@ -0,0 +1,255 @@
+// Package streaks is the single streak engine used for persisted streaks and analytics.
+//
+// Compute is a pure function: everything it depends on, including the
+// current time, comes in through Input.
+package streaks
+
+import (
+	"encoding/json"
+	"habit-tracker/backend/models"
+	"sort"
+	"time"
+)
+
+// Input describes a habit's history
+type Input struct {
+	// Dates are the days the habit counted as done, or for quit habits the days with a slip.
+	// Only the calendar date of each value is used.
+	Dates    []time.Time
+	Kind     models.HabitKind
+	Schedule models.Schedule
+	// Start is when tracking began; quit habits are clean from then on until the first slip
+	Start    time.Time
+	Location *time.Location
+	Clock    Clock
+}
+
+// Segment is one unbroken streak. Start and End are the first and last day it covers,
+// Length is counted in the schedule's periods (days for daily and quit habits).
+type Segment struct {
+	Start  time.Time
+	End    time.Time
+	Length int
+}
+
+// MarshalJSON writes the segment's days as plain dates
+func (s Segment) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Start  string `json:"start"`
+		End    string `json:"end"`
+		Length int    `json:"length"`
+	}{s.Start.Format("2006-01-02"), s.End.Format("2006-01-02"), s.Length})
+}
+
+// Result is the outcome of Compute
+type Result struct {
+	// Start is the first day of the history: the first completion, or for quit habits
+	// the start of tracking. It is zero for a build habit that was never done.
+	Start    time.Time
+	Current  int
+	Longest  int
+	Segments []Segment
+	// CompletionRate is the percentage of due periods that were met, or for quit habits of clean days
+	CompletionRate float64
+	// Strength is today's strength score from 0 to 100, the last entry of StrengthHistory
+	Strength        float64
+	StrengthHistory []models.DayStrength
+}
+
+// Compute derives the streaks of a habit as seen from the user's current day
+func Compute(in Input) Result {
+	loc := in.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	clock := in.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	today := Today(clock, loc)
+
+	var res Result
+	if in.Kind == models.HabitQuit {
+		res = computeQuit(uniqueDays(in.Dates), Day(in.Start.In(loc)), today)
+	} else {
+		days := uniqueDays(in.Dates)
+		var ps []period
+		if len(days) > 0 {
+			ps = periods(in.Schedule, days[0], days, today)
+		}
+		res = computeScheduled(ps, today)
+		if len(days) > 0 {
+			res.Start = days[0]
+		}
+	}
+
+	res.StrengthHistory = Strength(in)
+	if n := len(res.StrengthHistory); n > 0 {
+		res.Strength = res.StrengthHistory[n-1].Strength
+	}
+	return res
+}
+
+// uniqueDays returns the distinct calendar days in dates, oldest first
+func uniqueDays(dates []time.Time) []time.Time {
+	seen := make(map[time.Time]bool)
+	var days []time.Time
+	for _, d := range dates {
+		day := Day(d)
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Before(days[j])
+	})
+	return days
+}
+
+// period is one window of a schedule together with how many completions it needs
+type period struct {
+	start time.Time // first day of the window
+	end   time.Time // first day after the window
+	quota int
+	done  int
+}
+
+func (p period) satisfied() bool {
+	return p.done >= p.quota
+}
+
+// periods splits the time from first up to today into the windows in which the
+// schedule is due, and counts the completions in each. Streaks start from the first
+// completion. Completions on days the schedule does not cover are ignored.
+func periods(schedule models.Schedule, first time.Time, days []time.Time, today time.Time) []period {
+	var ps []period
+	switch schedule.Type {
+	case models.ScheduleWeekdays:
+		due := make(map[time.Weekday]bool)
+		for _, d := range schedule.Weekdays {
+			due[d] = true
+		}
+		for d := first; !d.After(today); d = d.AddDate(0, 0, 1) {
+			if due[d.Weekday()] {
+				ps = append(ps, period{start: d, end: d.AddDate(0, 0, 1), quota: 1})
+			}
+		}
+	case models.ScheduleTimesPerWeek:
+		// Weeks start on Monday
+		start := first.AddDate(0, 0, -((int(first.Weekday()) + 6) % 7))
+		for d := start; !d.After(today); d = d.AddDate(0, 0, 7) {
+			ps = append(ps, period{start: d, end: d.AddDate(0, 0, 7), quota: schedule.Times})
+		}
+	case models.ScheduleTimesPerMonth:
+		start := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC)
+		for d := start; !d.After(today); d = d.AddDate(0, 1, 0) {
+			ps = append(ps, period{start: d, end: d.AddDate(0, 1, 0), quota: schedule.Times})
+		}
+	case models.ScheduleEveryNDays:
+		// Windows of N days anchored on first
+		for d := first; !d.After(today); d = d.AddDate(0, 0, schedule.Interval) {
+			ps = append(ps, period{start: d, end: d.AddDate(0, 0, schedule.Interval), quota: 1})
+		}
+	default:
+		for d := first; !d.After(today); d = d.AddDate(0, 0, 1) {
+			ps = append(ps, period{start: d, end: d.AddDate(0, 0, 1), quota: 1})
+		}
+	}
+
+	// Both slices are sorted, so walk them together
+	i := 0
+	for _, day := range days {
+		for i < len(ps) && !day.Before(ps[i].end) {
+			i++
+		}
+		if i == len(ps) {
+			break
+		}
+		if !day.Before(ps[i].start) {
+			ps[i].done++
+		}
+	}
+	return ps
+}
+
+// computeScheduled finds the runs of satisfied periods. The period containing today
+// is still open, so it only counts once satisfied and never breaks the current streak.
+func computeScheduled(ps []period, today time.Time) Result {
+	res := Result{Segments: []Segment{}}
+	var seg *Segment
+	for _, p := range ps {
+		if !p.satisfied() {
+			seg = nil
+			continue
+		}
+		if seg == nil {
+			res.Segments = append(res.Segments, Segment{Start: p.start})
+			seg = &res.Segments[len(res.Segments)-1]
+		}
+		seg.Length++
+		seg.End = p.end.AddDate(0, 0, -1)
+		if seg.End.After(today) {
+			seg.End = today
+		}
+		res.Longest = max(res.Longest, seg.Length)
+	}
+
+	// Only a period that runs past today can still be met; a weekdays schedule's
+	// last due day may well be over already
+	open := len(ps) > 0 && ps[len(ps)-1].end.After(today) && !ps[len(ps)-1].satisfied()
+	closed := ps
+	if open {
+		closed = ps[:len(ps)-1]
+	}
+	if len(closed) > 0 && closed[len(closed)-1].satisfied() && len(res.Segments) > 0 {
+		res.Current = res.Segments[len(res.Segments)-1].Length
+	}
+
+	// Rate over the closed periods plus the open one once it is met
+	due, done := 0, 0
+	for _, p := range closed {
+		due += p.quota
+		done += min(p.done, p.quota)
+	}
+	if due > 0 {
+		res.CompletionRate = float64(done) / float64(due) * 100
+	}
+	return res
+}
+
+// computeQuit treats every day from start without a slip as a success. The current
+// streak is the number of days since the last slip, counting today until a slip is
+// logged for it.
+func computeQuit(slips []time.Time, start, today time.Time) Result {
+	if len(slips) > 0 && slips[0].Before(start) {
+		start = slips[0] // slips logged for days before the habit was created
+	}
+
+	res := Result{Start: start, Segments: []Segment{}}
+	addClean := func(from, to time.Time) {
+		if length := daysBetween(from, to) + 1; length > 0 {
+			res.Segments = append(res.Segments, Segment{Start: from, End: to, Length: length})
+			res.Longest = max(res.Longest, length)
+		}
+	}
+
+	// Pretend there was a slip the day before tracking started
+	prev := start.AddDate(0, 0, -1)
+	slipped := 0
+	for _, slip := range slips {
+		if slip.After(today) {
+			break
+		}
+		addClean(prev.AddDate(0, 0, 1), slip.AddDate(0, 0, -1))
+		prev = slip
+		slipped++
+	}
+	res.Current = max(daysBetween(prev, today), 0)
+	addClean(prev.AddDate(0, 0, 1), today)
+
+	if tracked := daysBetween(start, today) + 1; tracked > 0 {
+		res.CompletionRate = float64(tracked-slipped) / float64(tracked) * 100
+	}
+	return res
+}
@@ -0,0 +1,273 @@
+package streaks
+
+import (
+	"habit-tracker/backend/models"
+	"math"
+	"testing"
+	"time"
+)
+
+// now is a Saturday afternoon; tests see it as "today" through a fixed clock
+var now = time.Date(2026, 10, 17, 15, 0, 0, 0, time.UTC)
+
+func day(s string) time.Time {
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func days(ss ...string) []time.Time {
+	var ds []time.Time
+	for _, s := range ss {
+		ds = append(ds, day(s))
+	}
+	return ds
+}
+
+func TestCompute(t *testing.T) {
+	monThu := models.Schedule{Type: models.ScheduleWeekdays, Weekdays: []time.Weekday{time.Monday, time.Thursday}}
+	twicePerWeek := models.Schedule{Type: models.ScheduleTimesPerWeek, Times: 2}
+
+	tests := []struct {
+		name     string
+		kind     models.HabitKind
+		schedule models.Schedule
+		start    time.Time
+		dates    []time.Time
+
+		current, longest, segments int
+		rate                       float64
+		first                      time.Time
+	}{
+		{
+			name:  "daily without completions",
+			start: day("2026-10-01"),
+		},
+		{
+			name:    "daily run ending today",
+			start:   day("2026-10-01"),
+			dates:   days("2026-10-14", "2026-10-15", "2026-10-16", "2026-10-17"),
+			current: 4, longest: 4, segments: 1, rate: 100,
+			first: day("2026-10-14"),
+		},
+		{
+			name:    "today not yet done keeps the streak",
+			start:   day("2026-10-01"),
+			dates:   days("2026-10-14", "2026-10-15", "2026-10-16"),
+			current: 3, longest: 3, segments: 1, rate: 100,
+			first: day("2026-10-14"),
+		},
+		{
+			name:    "gap splits the segments",
+			start:   day("2026-10-01"),
+			dates:   days("2026-10-10", "2026-10-11", "2026-10-12", "2026-10-14", "2026-10-15", "2026-10-16"),
+			current: 3, longest: 3, segments: 2, rate: 600.0 / 7,
+			first: day("2026-10-10"),
+		},
+		{
+			name:    "missing yesterday breaks the streak",
+			start:   day("2026-10-01"),
+			dates:   days("2026-10-14", "2026-10-15"),
+			current: 0, longest: 2, segments: 1, rate: 200.0 / 3,
+			first: day("2026-10-14"),
+		},
+		{
+			name:    "duplicates and times of day collapse to days",
+			start:   day("2026-10-01"),
+			dates:   []time.Time{day("2026-10-16").Add(8 * time.Hour), day("2026-10-16").Add(22 * time.Hour), day("2026-10-17")},
+			current: 2, longest: 2, segments: 1, rate: 100,
+			first:    day("2026-10-16"),
+			schedule: models.DailySchedule(),
+		},
+		{
+			name:     "weekdays kept",
+			schedule: monThu,
+			start:    day("2026-10-01"),
+			dates:    days("2026-10-05", "2026-10-08", "2026-10-12", "2026-10-15"),
+			current:  4, longest: 4, segments: 1, rate: 100,
+			first: day("2026-10-05"),
+		},
+		{
+			name:     "weekdays missed on the last due day",
+			schedule: monThu,
+			start:    day("2026-10-01"),
+			dates:    days("2026-10-05", "2026-10-08", "2026-10-12"),
+			current:  0, longest: 3, segments: 1, rate: 75,
+			first: day("2026-10-05"),
+		},
+		{
+			name:     "weekdays ignore completions on other days",
+			schedule: monThu,
+			start:    day("2026-10-01"),
+			dates:    days("2026-10-05", "2026-10-06", "2026-10-08"),
+			current:  0, longest: 2, segments: 1, rate: 50,
+			first: day("2026-10-05"),
+		},
+		{
+			name:     "times per week with the current week open",
+			schedule: twicePerWeek,
+			start:    day("2026-09-01"),
+			dates:    days("2026-09-29", "2026-10-01", "2026-10-06", "2026-10-08", "2026-10-13"),
+			current:  2, longest: 2, segments: 1, rate: 100,
+			first: day("2026-09-29"),
+		},
+		{
+			name:     "times per week short week",
+			schedule: twicePerWeek,
+			start:    day("2026-09-01"),
+			dates:    days("2026-09-29", "2026-10-01", "2026-10-06"),
+			current:  0, longest: 1, segments: 1, rate: 75,
+			first: day("2026-09-29"),
+		},
+		{
+			name:     "every three days",
+			schedule: models.Schedule{Type: models.ScheduleEveryNDays, Interval: 3},
+			start:    day("2026-10-01"),
+			dates:    days("2026-10-08", "2026-10-11", "2026-10-14", "2026-10-17"),
+			current:  4, longest: 4, segments: 1, rate: 100,
+			first: day("2026-10-08"),
+		},
+		{
+			name:    "quit without slips",
+			kind:    models.HabitQuit,
+			start:   day("2026-10-10").Add(12 * time.Hour),
+			current: 8, longest: 8, segments: 1, rate: 100,
+			first: day("2026-10-10"),
+		},
+		{
+			name:    "quit slip today",
+			kind:    models.HabitQuit,
+			start:   day("2026-10-10"),
+			dates:   days("2026-10-17"),
+			current: 0, longest: 7, segments: 1, rate: 87.5,
+			first: day("2026-10-10"),
+		},
+		{
+			name:    "quit slip before creation moves the start",
+			kind:    models.HabitQuit,
+			start:   day("2026-10-15"),
+			dates:   days("2026-10-12"),
+			current: 5, longest: 5, segments: 1, rate: 500.0 / 6,
+			first: day("2026-10-12"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind := tt.kind
+			if kind == "" {
+				kind = models.HabitBuild
+			}
+			schedule := tt.schedule
+			if schedule.Type == "" {
+				schedule = models.DailySchedule()
+			}
+
+			res := Compute(Input{
+				Dates:    tt.dates,
+				Kind:     kind,
+				Schedule: schedule,
+				Start:    tt.start,
+				Location: time.UTC,
+				Clock:    FixedClock(now),
+			})
+
+			if res.Current != tt.current || res.Longest != tt.longest {
+				t.Errorf("current, longest = %d, %d, want %d, %d", res.Current, res.Longest, tt.current, tt.longest)
+			}
+			if len(res.Segments) != tt.segments {
+				t.Errorf("got %d segments, want %d: %+v", len(res.Segments), tt.segments, res.Segments)
+			}
+			if math.Abs(res.CompletionRate-tt.rate) > 1e-9 {
+				t.Errorf("completion rate = %v, want %v", res.CompletionRate, tt.rate)
+			}
+			if !res.Start.Equal(tt.first) {
+				t.Errorf("start = %v, want %v", res.Start, tt.first)
+			}
+		})
+	}
+}
+
+func FuzzCompute(f *testing.F) {
+	f.Add(uint64(0), false, uint8(0), uint8(1), uint8(30))
+	f.Add(uint64(0xF0F0F0F0F0F0F0F0), false, uint8(1), uint8(0x12), uint8(10))
+	f.Add(uint64(0xFFFFFFFFFFFFFFFF), false, uint8(2), uint8(3), uint8(0))
+	f.Add(uint64(0x5555555555555555), false, uint8(3), uint8(4), uint8(64))
+	f.Add(uint64(0x8000000000000001), true, uint8(4), uint8(2), uint8(5))
+
+	f.Fuzz(func(t *testing.T, bits uint64, quit bool, scheduleType, param, startDaysAgo uint8) {
+		today := Day(now)
+
+		// Bit i set means the habit was done (or slipped) i days ago
+		var dates []time.Time
+		for i := 0; i < 64; i++ {
+			if bits&(1<<i) != 0 {
+				dates = append(dates, today.AddDate(0, 0, -i))
+			}
+		}
+
+		schedule := models.DailySchedule()
+		switch scheduleType % 5 {
+		case 1:
+			schedule = models.Schedule{Type: models.ScheduleWeekdays}
+			for d := time.Sunday; d <= time.Saturday; d++ {
+				if param&(1<<d) != 0 {
+					schedule.Weekdays = append(schedule.Weekdays, d)
+				}
+			}
+			if len(schedule.Weekdays) == 0 {
+				schedule.Weekdays = []time.Weekday{time.Monday}
+			}
+		case 2:
+			schedule = models.Schedule{Type: models.ScheduleTimesPerWeek, Times: int(param%7) + 1}
+		case 3:
+			schedule = models.Schedule{Type: models.ScheduleTimesPerMonth, Times: int(param%31) + 1}
+		case 4:
+			schedule = models.Schedule{Type: models.ScheduleEveryNDays, Interval: int(param%14) + 1}
+		}
+		kind := models.HabitBuild
+		if quit {
+			kind = models.HabitQuit
+		}
+
+		res := Compute(Input{
+			Dates:    dates,
+			Kind:     kind,
+			Schedule: schedule,
+			Start:    today.AddDate(0, 0, -int(startDaysAgo)),
+			Location: time.UTC,
+			Clock:    FixedClock(now),
+		})
+
+		if res.Current < 0 || res.Current > res.Longest {
+			t.Fatalf("current %d not within 0 and longest %d", res.Current, res.Longest)
+		}
+		if res.CompletionRate < 0 || res.CompletionRate > 100 {
+			t.Fatalf("completion rate %v not within 0 and 100", res.CompletionRate)
+		}
+		if res.Strength < 0 || res.Strength > 100 {
+			t.Fatalf("strength %v not within 0 and 100", res.Strength)
+		}
+
+		longest := 0
+		var prevEnd time.Time
+		for i, seg := range res.Segments {
+			if seg.Length < 1 || seg.End.Before(seg.Start) || seg.End.After(today) {
+				t.Fatalf("invalid segment %+v", seg)
+			}
+			if i > 0 && !seg.Start.After(prevEnd) {
+				t.Fatalf("segment %+v overlaps the one ending %v", seg, prevEnd)
+			}
+			prevEnd = seg.End
+			longest = max(longest, seg.Length)
+		}
+		if longest != res.Longest {
+			t.Fatalf("longest %d, but the longest segment is %d", res.Longest, longest)
+		}
+		if res.Current > 0 && res.Segments[len(res.Segments)-1].Length != res.Current {
+			t.Fatalf("current %d does not match the last segment %+v", res.Current, res.Segments[len(res.Segments)-1])
+		}
+	})
+}
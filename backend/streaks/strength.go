@@ -0,0 +1,121 @@
+package streaks
+
+import (
+	"habit-tracker/backend/models"
+	"math"
+	"time"
+)
+
+const (
+	// strengthHalfLife is how many due days it takes a daily habit's strength to close
+	// half the gap to 0 or 100, the same decay as Loop Habit Tracker's score
+	strengthHalfLife = 13
+	// StrengthHistoryDays is how far back the history goes. Scoring starts as far again
+	// before that, by which time even the slowest schedules have forgotten where they started.
+	StrengthHistoryDays = 2 * 366
+)
+
+// Strength scores a habit from 0 to 100 for every day from the start of tracking, or
+// StrengthHistoryDays ago if that is later, up to today. Each day moves the score
+// toward 100 if the habit was on schedule and toward 0 if not, weighting recent days
+// exponentially more than old ones, so one missed day costs a little instead of
+// resetting everything like a streak does.
+//
+// A day is on schedule when the completions in the schedule's window ending on it
+// meet the quota: the day itself for daily habits, the last 7 days for times per
+// week, the last 30 for times per month and the last N for every N days. Partial
+// progress counts proportionally. Habits on fixed weekdays only move on those days,
+// quit habits move up on every day without a slip. Today is still open, so it can
+// raise the score but never lower it.
+func Strength(in Input) []models.DayStrength {
+	loc := in.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	clock := in.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	today := Today(clock, loc)
+
+	days := uniqueDays(in.Dates)
+	start := Day(in.Start.In(loc))
+	if len(days) > 0 && days[0].Before(start) {
+		start = days[0]
+	}
+	if start.After(today) {
+		return []models.DayStrength{}
+	}
+	// Days before keep only warm the score up, so cost is bounded however old the habit is
+	keep := today.AddDate(0, 0, 1-StrengthHistoryDays)
+	if warmUp := keep.AddDate(0, 0, -StrengthHistoryDays); start.Before(warmUp) {
+		start = warmUp
+	}
+
+	done := make(map[time.Time]bool, len(days))
+	for _, d := range days {
+		done[d] = true
+	}
+
+	window, quota := 1, 1
+	var due map[time.Weekday]bool
+	switch s := in.Schedule; {
+	case in.Kind == models.HabitQuit:
+	case s.Type == models.ScheduleWeekdays:
+		due = make(map[time.Weekday]bool)
+		for _, d := range s.Weekdays {
+			due[d] = true
+		}
+	case s.Type == models.ScheduleTimesPerWeek:
+		window, quota = 7, s.Times
+	case s.Type == models.ScheduleTimesPerMonth:
+		window, quota = 30, s.Times
+	case s.Type == models.ScheduleEveryNDays:
+		window = s.Interval
+	}
+	// Habits due less often than daily decay more slowly per day
+	frequency := float64(quota) / float64(window)
+	multiplier := math.Pow(0.5, math.Sqrt(frequency)/strengthHalfLife)
+
+	result := make([]models.DayStrength, 0, min(daysBetween(start, today)+1, StrengthHistoryDays))
+	strength := 0.0
+	// Completions in the window ending on d, counting those before start
+	inWindow := 0
+	for d := start.AddDate(0, 0, 1-window); d.Before(start); d = d.AddDate(0, 0, 1) {
+		if done[d] {
+			inWindow++
+		}
+	}
+	for d := start; !d.After(today); d = d.AddDate(0, 0, 1) {
+		if done[d] {
+			inWindow++
+		}
+		if done[d.AddDate(0, 0, -window)] {
+			inWindow--
+		}
+
+		var value float64
+		switch {
+		case due != nil && !due[d.Weekday()]:
+			if !d.Before(keep) {
+				result = append(result, models.DayStrength{Date: d, Strength: strength})
+			}
+			continue
+		case in.Kind == models.HabitQuit:
+			if !done[d] {
+				value = 1
+			}
+		default:
+			value = math.Min(1, float64(inWindow)/float64(quota))
+		}
+
+		next := strength*multiplier + value*100*(1-multiplier)
+		if !d.Equal(today) || next > strength {
+			strength = next
+		}
+		if !d.Before(keep) {
+			result = append(result, models.DayStrength{Date: d, Strength: strength})
+		}
+	}
+	return result
+}
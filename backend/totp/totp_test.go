@@ -0,0 +1,107 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors, "12345678901234567890"
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeMatchesRFC6238(t *testing.T) {
+	// The RFC lists eight digits; six digit codes are their last six
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSkewWindow(t *testing.T) {
+	// Issued at the start of a period, checked by a server clock that drifted
+	issued := time.Unix(1_800_000_000, 0).Truncate(Period)
+	code, err := Code(rfcSecret, Step(issued))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		drift time.Duration
+		ok    bool
+	}{
+		{"same period", 29 * time.Second, true},
+		{"one period later", Period, true},
+		{"end of the next period", 2*Period - time.Second, true},
+		{"two periods later", 2 * Period, false},
+		{"one period earlier", -time.Second, true},
+		{"start of the previous period", -Period, true},
+		{"two periods earlier", -Period - time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, code, issued.Add(tt.drift))
+			if ok != tt.ok {
+				t.Fatalf("Validate = %v, want %v", ok, tt.ok)
+			}
+			// Replay protection relies on every accepted check naming the issuing step
+			if ok && step != Step(issued) {
+				t.Errorf("step = %d, want %d", step, Step(issued))
+			}
+		})
+	}
+}
+
+func TestValidateReplayReportsTheSameStep(t *testing.T) {
+	now := time.Unix(1_800_000_015, 0)
+	code, err := Code(rfcSecret, Step(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, ok := Validate(rfcSecret, code, now)
+	if !ok {
+		t.Fatal("fresh code rejected")
+	}
+	// Still inside the skew window, the code validates again, so the caller
+	// must refuse any step not newer than the last one it accepted
+	again, ok := Validate(rfcSecret, code, now.Add(Period))
+	if !ok || again != first {
+		t.Fatalf("replayed code: step %d ok %v, want step %d", again, ok, first)
+	}
+	next, err := Code(rfcSecret, Step(now)+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step, ok := Validate(rfcSecret, next, now.Add(Period)); !ok || step <= first {
+		t.Fatalf("next code: step %d ok %v, want a step after %d", step, ok, first)
+	}
+}
+
+func TestValidateRejectsMalformedCodes(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	for _, code := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := Validate(rfcSecret, code, now); ok {
+			t.Errorf("Validate(%q) accepted", code)
+		}
+	}
+	if _, ok := Validate("not base32!", "123456", now); ok {
+		t.Error("invalid secret accepted")
+	}
+}